@@ -0,0 +1,132 @@
+package flag
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+//Clone returns a new FlagSet holding the flags of fs, with their tags,
+//requirements, transforms, factories and OnSet functions, and the options set
+//on fs (environment prefix, separators, output...), but none of the values
+//parsed by fs. The flags of the configuration structure are bound to config,
+//which must be a pointer to a structure of the same type, or to a new zeroed
+//structure if config is nil: the clone never writes to the configuration
+//structure of fs. Flags registered with the Var methods or imported with Merge
+//keep writing to their own variables, shared with fs. Subcommands are not
+//cloned: they are registered on the clone with AddCommand. Clone returns nil if
+//config does not match the configuration structure of fs.
+//For example, to parse in a goroutine:
+// c := &config{}
+// clone := fs.Clone(c)
+// err := clone.ParseArgs(args)
+//
+func (fs *FlagSet) Clone(config interface{}) *FlagSet {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if fs.config != nil && config == nil {
+		config = reflect.New(reflect.TypeOf(fs.config).Elem()).Interface()
+	}
+	if reflect.TypeOf(fs.config) != reflect.TypeOf(config) || (config != nil && reflect.ValueOf(config).IsNil()) {
+		fmt.Fprintf(fs.output, "configuration structure of type %T expected to clone the FlagSet\n", fs.config)
+		return nil
+	}
+
+	clone := &FlagSet{
+		config:          config,
+		fmap:            make(map[string]*flag),
+		flags:           make([]*flag, 0, len(fs.flags)),
+		envPrefix:       fs.envPrefix,
+		autoEnv:         fs.autoEnv,
+		envUpper:        fs.envUpper,
+		flagAsValue:     fs.flagAsValue,
+		continueOnError: fs.continueOnError,
+		errs:            make([]error, 0),
+		ignoreMissing:   fs.ignoreMissing,
+		anyDashes:       fs.anyDashes,
+		prefixMatch:     fs.prefixMatch,
+		omitDefaults:    fs.omitDefaults,
+		output:          fs.output,
+		requirements:    make(map[*flag][]*flag),
+		interspersed:    fs.interspersed,
+		args:            make([]string, 0),
+		truthy:          append([]string{}, fs.truthy...),
+		falsy:           append([]string{}, fs.falsy...),
+		parsed:          false,
+		help:            nil,
+		version:         nil,
+		versionString:   fs.versionString,
+		commands:        make(map[string]*FlagSet),
+		command:         "",
+		commandArgs:     make([]string, 0),
+		warnUnsetEnv:    fs.warnUnsetEnv,
+		fileValues:      fs.fileValues,
+		stdin:           fs.stdin,
+		input:           fs.input,
+		argsSource:      fs.argsSource,
+		stdinFlag:       nil,
+		stoppedBy:       nil,
+		responseFiles:   fs.responseFiles,
+		expandEnv:       fs.expandEnv,
+		dryRun:          false,
+		defaultSep:      fs.defaultSep,
+		dotenv:          nil,
+		ctx:             fs.ctx,
+		onSet:           make(map[*flag][]func(values []string) error),
+		mu:              sync.RWMutex{},
+	}
+
+	cloned := make(map[*flag]*flag, len(fs.flags))
+	for _, fitem := range fs.flags {
+		c := *fitem
+		c.names = append([]string{}, fitem.names...)
+		c.values = make([]string, 0)
+		c.isSet = false
+		c.source = fromDefault
+		c.defaults = append([]string{}, fitem.defaults...)
+		c.transforms = append([]func(string) (string, error){}, fitem.transforms...)
+		if fs.owns(fitem) {
+			c.field = reflect.ValueOf(config).Elem().Field(fitem.index)
+			if !c.hasDefault {
+				c.defaults = c.fieldValues(c.field)
+			}
+			if c.appendTo {
+				c.base = reflect.MakeSlice(c.field.Type(), 0, c.field.Len())
+				c.base = reflect.AppendSlice(c.base, c.field)
+			}
+		}
+		cloned[fitem] = &c
+		for _, name := range c.names {
+			clone.fmap[name] = &c
+		}
+		clone.flags = append(clone.flags, &c)
+	}
+	for fitem, required := range fs.requirements {
+		for _, ritem := range required {
+			clone.requirements[cloned[fitem]] = append(clone.requirements[cloned[fitem]], cloned[ritem])
+		}
+	}
+	for fitem, fns := range fs.onSet {
+		clone.onSet[cloned[fitem]] = append([]func(values []string) error{}, fns...)
+	}
+	if fs.help != nil {
+		clone.help = cloned[fs.help]
+	}
+	if fs.version != nil {
+		clone.version = cloned[fs.version]
+	}
+	return clone
+}
+
+//owns reports whether fitem is bound to a field of the configuration
+//structure of fs, rather than to a variable registered with a Var method or a
+//field of a merged FlagSet
+func (fs *FlagSet) owns(fitem *flag) bool {
+	config := reflect.ValueOf(fs.config)
+	if fs.config == nil || fitem.index < 0 || fitem.index >= config.Elem().NumField() {
+		return false
+	}
+	field := config.Elem().Field(fitem.index)
+	return field.Addr().Pointer() == fitem.field.Addr().Pointer() && field.Type() == fitem.field.Type()
+}
@@ -0,0 +1,27 @@
+package flag
+
+import "fmt"
+
+//AddCommand registers sub as the FlagSet of the subcommand name. When the
+//first positional argument found on the command line is a registered
+//subcommand, the flags before it are parsed by fs and every argument after it
+//is parsed by sub, once fs is done. For example, with "app -v commit -m msg",
+//-v is parsed by fs and "-m msg" by the FlagSet registered as commit.
+func (fs *FlagSet) AddCommand(name string, sub *FlagSet) error {
+	if len(name) == 0 || sub == nil {
+		return fmt.Errorf("a subcommand requires a name and a FlagSet")
+	}
+	if _, ok := fs.commands[name]; ok {
+		return fmt.Errorf("subcommand %s already registered", name)
+	}
+	fs.commands[name] = sub
+	return nil
+}
+
+//Command returns the name of the subcommand found on the command line, empty
+//if none
+func (fs *FlagSet) Command() string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.command
+}
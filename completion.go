@@ -0,0 +1,134 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var notIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+//GenBashCompletion writes to w a bash completion script for the program
+//progName. Flag names are completed, hidden flags excepted, as well as the
+//values of flags with a choices tag and the subcommands. Other values are
+//completed as file names. For example:
+// $ app completion bash > /etc/bash_completion.d/app
+//
+func (fs *FlagSet) GenBashCompletion(w io.Writer, progName string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	b := &strings.Builder{}
+	fn := "_" + notIdentifier.ReplaceAllString(progName, "_") + "_completion"
+	fmt.Fprintf(b, "# bash completion for %s\n", progName)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	b.WriteString("\tlocal cur prev\n")
+	b.WriteString("\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tprev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("\tcase \"$prev\" in\n")
+	for _, fitem := range fs.completed() {
+		if fitem.valuation == none {
+			continue
+		}
+		fmt.Fprintf(b, "\t%s)\n", strings.Join(fitem.names, "|"))
+		if len(fitem.choices) != 0 {
+			fmt.Fprintf(b, "\t\tCOMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", shellQuote(strings.Join(fitem.choices, " ")))
+		}
+		b.WriteString("\t\treturn 0\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(b, "\t\tCOMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", shellQuote(strings.Join(fs.completedNames(), " ")))
+	b.WriteString("\t\treturn 0\n\tfi\n")
+	if len(fs.commands) != 0 {
+		fmt.Fprintf(b, "\tCOMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", shellQuote(strings.Join(fs.commandNames(), " ")))
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(b, "complete -o default -F %s %s\n", fn, progName)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//GenZshCompletion writes to w a zsh completion script for the program
+//progName, completing the same flags, values and subcommands as
+//GenBashCompletion
+func (fs *FlagSet) GenZshCompletion(w io.Writer, progName string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "#compdef %s\n\n", progName)
+	b.WriteString("_arguments")
+	for _, fitem := range fs.completed() {
+		for _, name := range fitem.names {
+			spec := name
+			if fitem.valuation == multi {
+				spec = "*" + spec
+			}
+			spec += "[" + zshEscape(fitem.usage) + "]"
+			if fitem.valuation != none {
+				action := "_files"
+				if len(fitem.choices) != 0 {
+					action = "(" + strings.Join(fitem.choices, " ") + ")"
+				}
+				spec += ":value:" + action
+			}
+			fmt.Fprintf(b, " \\\n\t%s", shellQuote(spec))
+		}
+	}
+	if len(fs.commands) != 0 {
+		fmt.Fprintf(b, " \\\n\t%s", shellQuote("1:command:("+strings.Join(fs.commandNames(), " ")+")"))
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//completed returns the flags offered for completion, the hidden and
+//environment only ones excepted
+func (fs *FlagSet) completed() []*flag {
+	flags := make([]*flag, 0, len(fs.flags))
+	for _, fitem := range fs.flags {
+		if fitem.hidden || len(fitem.names) == 0 {
+			continue
+		}
+		flags = append(flags, fitem)
+	}
+	return flags
+}
+
+//completedNames returns the names of the flags offered for completion
+func (fs *FlagSet) completedNames() []string {
+	names := make([]string, 0, len(fs.fmap))
+	for _, fitem := range fs.completed() {
+		names = append(names, fitem.names...)
+	}
+	return names
+}
+
+//commandNames returns the sorted names of the subcommands
+func (fs *FlagSet) commandNames() []string {
+	names := make([]string, 0, len(fs.commands))
+	for name := range fs.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//zshEscape escapes the characters of s having a meaning in a zsh option
+//description
+func zshEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`)
+	return r.Replace(s)
+}
+
+//shellQuote returns s between single quotes, each single quote of s being
+//written '\'', so that the shell expands none of its characters
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
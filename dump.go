@@ -0,0 +1,24 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//Dump writes to w the value held by the configuration structure for each flag
+//and where it comes from (command line, environment, file, Set or default).
+//Values are written the way they are given on the command line, the values of
+//a multivaluated flag being joined with its separator (or a comma). It is
+//meant to be called after Parse to report the effective configuration.
+func (fs *FlagSet) Dump(w io.Writer) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	for _, fitem := range fs.flags {
+		sep := fitem.separator
+		if len(sep) == 0 {
+			sep = ","
+		}
+		fmt.Fprintf(w, "%s: %s (%s)\n", fitem.name(), strings.Join(fitem.fieldValues(fitem.field), sep), fitem.source)
+	}
+}
@@ -0,0 +1,60 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrHelp is returned when the help flag is set on the command line
+var ErrHelp = errors.New("flag: help requested")
+
+//ErrVersion is returned when the version flag is set on the command line
+var ErrVersion = errors.New("flag: version requested")
+
+//ErrTerminal is returned when a flag with the terminal tag is found on the
+//command line, parsing being stopped
+var ErrTerminal = errors.New("flag: terminal flag found")
+
+//ErrUnknownFlag is returned when the command line holds a flag which is not
+//registered
+var ErrUnknownFlag = errors.New("unknown flag")
+
+//ErrAmbiguousFlag is returned when a prefix given on the command line matches
+//several long flags
+var ErrAmbiguousFlag = errors.New("ambiguous flag")
+
+//ErrMissingValue is returned when a flag expecting a value is not given any
+var ErrMissingValue = errors.New("missing value for flag")
+
+//ErrDuplicateMono is returned when a monovaluated flag is set more than once
+var ErrDuplicateMono = errors.New("value already set for flag")
+
+//ErrTooManyValues is returned when a multivaluated flag is given more values
+//than its max-count tag allows
+var ErrTooManyValues = errors.New("too many values for flag")
+
+//ErrRequiredFlag is returned when a flag required is not set
+var ErrRequiredFlag = errors.New("missing required flag")
+
+//ErrInvalidValue is returned when a value does not satisfy the constraints set
+//on a flag
+var ErrInvalidValue = errors.New("invalid value")
+
+//ConversionError is returned when a value can not be converted to the type of
+//the field associated with the flag. Index is the position of the value among
+//the values of a multivaluated flag, -1 otherwise.
+type ConversionError struct {
+	Flag  string
+	Value string
+	Index int
+	Err   error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("invalid value %s for flag %s: %s", quoteValue(e.Value, e.Index), e.Flag, e.Err)
+}
+
+//Unwrap returns the underlying conversion error
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
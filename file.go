@@ -0,0 +1,347 @@
+package flag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//ParseWithFile parses the command line and populates provided configuration
+//structure like Parse, using the JSON file at path as the source with the lowest
+//precedence: a value from the file is only used if the flag is set neither on
+//the command line nor with an environment variable.
+//The file holds a JSON object whose keys are the first long name of each flag
+//(or its first name if it has no long name) without the leading dashes. For
+//example:
+// {
+//	"server": ["10.0.0.1", "10.0.0.2"],
+//	"interval": 10,
+//	"boolean": true
+// }
+//
+func (fs *FlagSet) ParseWithFile(path string) error {
+	return fs.parse(fs.commandLine(), path)
+}
+
+//ParseReader parses the command line and populates provided configuration
+//structure like Parse, using the NAME=value lines read from r as environment
+//variables: NAME is matched against the file tag of a flag, or its
+//environment variable name (see SetEnvPrefix and AutoEnv). A value read from
+//r is only used if the flag is set neither on the command line nor with its
+//environment variable, the actual environment taking precedence over r.
+//Blank lines and lines starting with # are skipped, a line may start with
+//"export ", and a value may be enclosed in double quotes (Go escapes being
+//interpreted) or single quotes (taken literally). Names matching no flag are
+//ignored. For example, with Token string `names:"--token" env:"APP_TOKEN"`:
+// # .env
+// APP_TOKEN="s3cr3t"
+//
+func (fs *FlagSet) ParseReader(r io.Reader) error {
+	entries, err := readDotenv(r)
+	if err != nil {
+		return fmt.Errorf("could not read values: %w", err)
+	}
+	args := fs.commandLine()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.dotenv = entries
+	defer func() { fs.dotenv = nil }()
+	return fs.run(args, "")
+}
+
+//readDotenv returns the NAME=value entries of the lines read from r
+func readDotenv(r io.Reader) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("line %d: NAME=value expected", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		entries[name] = value
+	}
+	return entries, scanner.Err()
+}
+
+//IgnoreMissingFile sets whether a missing file given to ParseWithFile is
+//ignored or returns an error (the default)
+func (fs *FlagSet) IgnoreMissingFile(ignore bool) {
+	fs.ignoreMissing = ignore
+}
+
+//AllowFileValues sets whether a value given on the command line as @path is
+//replaced by the content of the file at path, a trailing newline removed. It
+//keeps secrets such as tokens or certificates out of the shell history and
+//the process list. A value starting with a literal @ is written @@, for
+//example --user @@admin sets the value @admin.
+func (fs *FlagSet) AllowFileValues(allow bool) {
+	fs.fileValues = allow
+}
+
+//AllowStdin sets whether the value "-" given on the command line to a
+//monovaluated flag is replaced by the content read from the standard input, a
+//trailing newline removed, for example to pipe a secret with
+//echo $TOKEN | app --token -. Only one flag can read the standard input on a
+//command line. With AllowFileValues, "-" is read from the standard input and
+//@- from the file named "-". Once enabled, "-" can not be given as is to a
+//monovaluated flag. Validate does not read the standard input, the zero value
+//of the field standing for it.
+func (fs *FlagSet) AllowStdin(allow bool) {
+	fs.stdin = allow
+}
+
+//SetInput sets the reader replacing os.Stdin for the values read from the
+//standard input with AllowStdin, nil being an empty input
+func (fs *FlagSet) SetInput(r io.Reader) {
+	if r == nil {
+		r = bytes.NewReader(nil)
+	}
+	fs.input = r
+}
+
+//AllowResponseFiles sets whether an argument @path is replaced, before
+//parsing, by the arguments held by the file at path, separated by spaces or
+//new lines. Lines starting with # are comments. A response file can include
+//other ones, but not itself. An argument starting with a literal @ is written
+//@@, and arguments following "--" are not expanded. Response files are
+//expanded before values given as @path with AllowFileValues are read: such a
+//value is given as --name=@path.
+func (fs *FlagSet) AllowResponseFiles(allow bool) {
+	fs.responseFiles = allow
+}
+
+//expandResponseFiles returns args, the arguments @path being replaced with
+//the content of the response files. included holds the files being expanded.
+//Files are not read once ctx is done.
+func expandResponseFiles(ctx context.Context, args []string, included []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(expanded, args[i:]...), nil
+		}
+		if !strings.HasPrefix(arg, "@") || len(arg) == 1 {
+			expanded = append(expanded, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "@@") {
+			expanded = append(expanded, arg[1:])
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path, err := filepath.Abs(arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range included {
+			if p == path {
+				return nil, fmt.Errorf("response file %s includes itself", arg[1:])
+			}
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read response file: %w", err)
+		}
+		tokens := make([]string, 0)
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			tokens = append(tokens, strings.Fields(line)...)
+		}
+		tokens, err = expandResponseFiles(ctx, tokens, append(included, path))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, tokens...)
+	}
+	return expanded, nil
+}
+
+//readValue returns the content of the file named by s if s starts with @, s
+//without its escaping @ if it starts with @@, and s unchanged otherwise. The
+//file is not read once ctx is done.
+func readValue(ctx context.Context, s string) (string, error) {
+	if !strings.HasPrefix(s, "@") {
+		return s, nil
+	}
+	if strings.HasPrefix(s, "@@") {
+		return s[1:], nil
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(s[1:])
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+//readInput returns the content read from r, a trailing newline removed. r is
+//not read once ctx is done.
+func readInput(ctx context.Context, r io.Reader) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+func (fs *FlagSet) parseFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && fs.ignoreMissing {
+			return nil
+		}
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	entries := make(map[string]interface{})
+	if err := d.Decode(&entries); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*flag)
+	for _, fitem := range fs.flags {
+		if len(fitem.names) == 0 {
+			continue
+		}
+		keys[strings.TrimLeft(longName(fitem.names), "-")] = fitem
+	}
+
+	for key, entry := range entries {
+		fitem, ok := keys[key]
+		if !ok {
+			if err := fs.fail(fmt.Errorf("%w %s", ErrUnknownFlag, key)); err != nil {
+				return err
+			}
+			continue
+		}
+		if fitem.isSet {
+			continue
+		}
+
+		//a field with the json tag is given the JSON document as is
+		if fitem.isJSON && entry != nil {
+			b, err := json.Marshal(entry)
+			if err != nil {
+				if err := fs.fail(fmt.Errorf("invalid value for %s: %s", key, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			fitem.values = append(fitem.values, string(b))
+			fitem.isSet = true
+			continue
+		}
+
+		values, err := jsonValues(entry)
+		if err != nil {
+			if err := fs.fail(fmt.Errorf("invalid value for %s: %s", key, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if fs.expandEnv {
+			for i := range values {
+				values[i] = os.ExpandEnv(values[i])
+			}
+		}
+
+		if fitem.valuation == none {
+			if len(values) != 1 {
+				if err := fs.fail(fmt.Errorf("invalid value for %s: boolean expected", key)); err != nil {
+					return err
+				}
+				continue
+			}
+			b, err := fs.parseBool(values[0])
+			if err != nil {
+				if err := fs.fail(&ConversionError{Flag: key, Value: values[0], Index: -1, Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			fitem.values = append(fitem.values, strconv.FormatBool(b))
+			fitem.isSet = true
+			continue
+		}
+
+		if fitem.valuation == mono && len(values) != 1 {
+			if err := fs.fail(fmt.Errorf("%w %s", ErrDuplicateMono, key)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fitem.values = append(fitem.values, values...)
+		fitem.isSet = true
+	}
+	return nil
+}
+
+//jsonValues returns the string representation of a decoded JSON value, which
+//must be a string, a number, a boolean, null or an array of those
+func jsonValues(entry interface{}) ([]string, error) {
+	switch e := entry.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{e}, nil
+	case json.Number:
+		return []string{e.String()}, nil
+	case bool:
+		return []string{strconv.FormatBool(e)}, nil
+	case []interface{}:
+		values := make([]string, 0, len(e))
+		for _, item := range e {
+			switch item.(type) {
+			case []interface{}, map[string]interface{}:
+				return nil, fmt.Errorf("nested arrays and objects are not supported")
+			}
+			v, err := jsonValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v...)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("objects are not supported")
+}
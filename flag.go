@@ -11,7 +11,48 @@ The following examples are equivalent from the application perspective.
  $ export SERVERS="10.0.0.1,10.0.0.2"
  $ ./app        # values are set using environment variables or default values
 
-Default values can be set within the application.
+Default values can be set within the application, or with the default tag,
+for example default:"8080". The default tag is used for a flag set neither on
+the command line, nor with an environment variable, nor from a file, and is
+converted like any other value. A flag holding its default value is not
+considered as set.
+
+A value can also be given in the same argument as the flag, separated with an
+equal sign: --server=10.0.0.1. This is the only way to give a value to a
+boolean flag, --boolean=false for example, a boolean flag alone being true.
+Boolean values are parsed the same way on the command line, in environment
+variables and files: --boolean=1 and --boolean=0 are accepted like any string
+accepted by strconv.ParseBool (see SetBoolStrings), --boolean=2 is an error.
+Unlike some other parsers, a boolean flag never takes the following argument
+as its value: with --boolean false, the flag is true and false is a positional
+argument.
+Short flags can be combined as with getopt: -vx is -v -x for boolean flags,
+and the value of a short flag can be appended to it: -ofile is -o file. Both
+apply to arguments which are not registered flags, and combine as in -vofile.
+A value starting with a dash is accepted unless it is a flag: negative numbers
+such as --offset -10 are values, a registered flag named like a number
+excepted.
+
+The env tag may list several environment variables separated with commas, for
+example env:"NEW_HOST,OLD_HOST" to rename a variable: the first one set is
+used, the command line still overriding all of them.
+
+A name given several times in the names tag of a field, as in names:"-v,-v",
+is registered once, the first occurrence keeping its position.
+
+A field with an env tag and an empty or no names tag can only be set with its
+environment variable, which is useful for secrets not to be exposed on the
+command line.
+The file tag names the entry of a flag in a NAME=value file given to
+ParseReader, for example file:"DB_PASSWORD". Like the env tag, it can be used
+without a names tag.
+
+Arguments which are not flags are positional arguments, available with the Args
+method once parsed. Parsing stops at the first positional argument unless
+interspersed flags are allowed, and always stops after "--". A "--" following
+a flag expecting a value is that value rather than the terminator: with
+--arg -- -v, arg holds "--" and -v is parsed, while with -v -- --arg x,
+"--arg" and "x" are positional arguments. Greedy flags stop at "--".
 
 Flags are categorized as boolean, monovaluated (1 and only 1 value can be set) or
 multivaluated (several values can be associated with a flag).
@@ -22,6 +63,128 @@ The following snippet declares :
 -i and --interval as a monovaluated flag, to stored as a uint64
 
 The sep tag allows the user to set several values at once using a separator.
+The envsep tag sets the separator used for the environment variable only, for
+example envsep:":" for a PATH-like variable while the command line repeats the
+flag. Without it, the environment variable is split with the sep tag.
+The envindexed tag, set to "true" on a slice or a map, reads its values from
+indexed environment variables when the variable itself is not set: with
+env:"SERVER", SERVER_0, SERVER_1... are read up to the first one not set, each
+one holding a single value. SERVER, if set, wins and the indexed variables are
+ignored.
+The max-count tag caps the number of values of a slice or a map, once the
+command line, environment and file values are merged, for example
+max-count:"8".
+The unique tag, set to "true" on a slice, drops duplicated values, keeping the
+first occurrence.
+Values of a slice are stored as given, surrounding spaces included, unless the
+trim tag is set to "true": with sep:"," trim:"true", "a, b" holds "a" and "b".
+Blank values, empty or made of spaces, are dropped unless the keepempty tag is
+set to "true": with sep:"," keepempty:"true", ",a,,b," holds "", "a", "", "b"
+and "". Both tags are independent; with sep:",", "a, , b," holds:
+ "a" and " b" without trim nor keepempty;
+ "a" and "b" with trim:"true";
+ "a", " ", " b" and "" with keepempty:"true";
+ "a", "", "b" and "" with both.
+A value holding no other value, such as --tags "" or --tags ",,", is an error
+unless the keepempty tag is set.
+A value holding the separator is enclosed in double quotes if the quoted tag
+is set to "true", values being split as a CSV record: with sep:","
+quoted:"true", '"a,b",c' holds "a,b" and "c", and a double quote within a
+quoted value is doubled. The separator must then be a single character.
+Values set for a slice replace the ones held by the configuration structure,
+unless the append tag is set to "true": values are then appended to the ones
+held when the FlagSet was created.
+The greedy tag, set to "true" on a slice, makes the flag take every following
+argument as a value, until "--" or the end of the command line: --files a b c
+holds "a", "b" and "c". Flags following a greedy flag are taken as values too:
+they must be given before it. Arguments after "--" are positional.
+An array is set like a slice and requires as many values as its length, for
+example --coords 1.5,2,0 for a [3]float64 with sep:",".
+A map is set with values formatted as key=value, for example
+--label env=prod --label team=core. The last value wins for a key given
+several times.
+The choices tag restricts the values accepted for a flag to a comma separated
+list, for example choices:"debug,info,warn,error". For slices, each element
+is checked once the command line, environment and file values are merged, and
+the error names the first invalid element.
+The enum tag maps names to the values of an integer field, for example
+enum:"debug=0,info=1,warn=2,error=3" on a field of type LogLevel int: with
+--level info, the field holds 1.
+The match tag restricts the values accepted for a flag to the ones matching a
+regular expression, for example match:"^[a-z0-9-]+$".
+The maxlen tag sets the maximum length of a string, or of each string of a
+slice or a map, counted in characters (runes) rather than bytes, for example
+maxlen:"64".
+The min and max tags set the range of values accepted for numbers, bounds
+included, for example min:"1" max:"65535".
+
+The group tag sorts a flag under a heading of the usage message, for example
+group:"Networking". Groups are listed in the order their first flag is
+declared, flags without a group tag being listed under "Options".
+
+The required tag, set to "true", makes parsing return ErrRequiredFlag if the
+flag is set neither on the command line, nor with an environment variable or
+a file. It can not be used with the default tag. MissingRequired lists the
+required flags not set.
+
+The disableenv tag names an environment variable acting as a kill switch, for
+example disableenv:"DISABLE_FEATURE_X": when it is set to true, the field is
+set to its zero value whatever the command line, environment variables, files
+and default tag hold. The name is used as is, without prefix.
+
+The hidden tag, set to "true", keeps a flag out of the usage message while it
+can still be used.
+
+The deprecated tag marks a flag as deprecated: it still works but a warning
+holding the tag value is written each time it is used on the command line, for
+example deprecated:"use --new-name instead".
+
+A []byte field holds the bytes of the value given, decoded first if the
+encoding tag is set to "base64" or "hex".
+
+The help tag, set to "true" on a boolean, makes it the help flag: when it is
+found on the command line, whatever the other arguments, parsing writes the
+usage message and returns ErrHelp.
+The version tag, set to "true" on a boolean, makes it the version flag: when it
+is found on the command line, parsing writes the version set with SetVersion and
+returns ErrVersion. If both help and version flags are found, help wins.
+The terminal tag, set to "true", stops parsing as soon as the flag is found on
+the command line: only this flag is set, the following arguments are
+positional arguments, and parsing returns ErrTerminal, the flag being given by
+the Terminal method. Unlike help and version flags, the flags found before it
+are parsed (and may fail) first.
+
+A rune (or int32) field with the rune tag set to "true" is set with a single
+character, for example --delimiter ",".
+
+Integers are parsed like Go integer literals: 1_000, 0x1F, 0o17 and 0b101 are
+accepted. A number with leading zeros, such as 010 or 08, is a decimal number:
+octal numbers are written with the 0o prefix, as in 0o755.
+
+An integer field with the unit tag set to "bytes" is set with a size, for
+example --buffer 10MB or --quota 1.5GiB: SI units (KB, MB, GB, TB, PB, EB)
+are powers of 1000 and binary units (KiB, MiB, GiB, TiB, PiB, EiB) powers of
+1024, regardless of case. A number without unit is a number of bytes and may
+be written as any integer literal, for example 0x10, whereas a number with a
+unit is a decimal number such as 1.5. The min and max tags of such a field
+accept sizes as well.
+
+Fields of type time.Duration are set using time.ParseDuration, for example
+"1m30s". Slices of time.Duration are supported as well, for example
+--backoff 1s,5s,30s with sep:",". The ConversionError returned for an invalid
+element of a slice holds its index.
+
+Interface fields are set by a factory selected by the value of the flag, see
+RegisterFactory.
+
+Structs, slices, arrays and maps with the json tag set to "true" are set from
+a JSON document unmarshaled with encoding/json, for example
+--filter '{"name":"web","replicas":2}'. Any other value of the json tag is left
+to encoding/json.
+
+Pointers to basic types (*int, *string, ...) are supported: the pointer is left
+nil if the flag is not set, making a difference between a flag not provided and
+a flag set to the zero value.
 
 type config struct {
 	Path     string   `names:"-p,--p"`
@@ -54,13 +217,28 @@ func main() {
 package flag
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+var durationType = reflect.TypeOf(time.Duration(0))
+
 type valuation int
 
 const (
@@ -69,16 +247,129 @@ const (
 	multi
 )
 
+type source int
+
+const (
+	fromDefault source = iota
+	fromCommandLine
+	fromEnv
+	fromFile
+	fromSet
+)
+
+func (s source) String() string {
+	switch s {
+	case fromCommandLine:
+		return "command line"
+	case fromEnv:
+		return "environment"
+	case fromFile:
+		return "file"
+	case fromSet:
+		return "Set"
+	}
+	return "default"
+}
+
 type flag struct {
-	names     []string
-	values    []string
-	valuation valuation
-	env       string
-	finalType reflect.Kind
-	index     int
-	usage     string
-	separator string
-	isSet     bool
+	names        []string
+	values       []string
+	valuation    valuation
+	env          string
+	envFallbacks []string
+	fileKey      string
+	finalType    reflect.Kind
+	index        int
+	field        reflect.Value
+	usage        string
+	group        string
+	deprecated   string
+	separator    string
+	explicitSep  bool
+	envSeparator string
+	envIndexed   bool
+	disableEnv   string
+	unique       bool
+	trim         bool
+	keepEmpty    bool
+	quoted       bool
+	greedy       bool
+	terminal     bool
+	appendTo     bool
+	hidden       bool
+	required     bool
+	encoding     string
+	unit         string
+	isRune       bool
+	isJSON       bool
+	choices      []string
+	match        *regexp.Regexp
+	enum         []enumValue
+	factories    map[string]func() interface{}
+	min          reflect.Value
+	max          reflect.Value
+	maxCount     int
+	maxLen       int
+	isSet        bool
+	source       source
+	defaults     []string
+	base         reflect.Value
+	defaultTag   string
+	hasDefault   bool
+	transforms   []func(string) (string, error)
+}
+
+//newFlag returns a flag with no names nor tags, bound to field, the field at
+//index in the configuration structure or a variable registered with one of
+//the Var methods (index being -1)
+func newFlag(field reflect.Value, index int, valuation valuation, kind reflect.Kind) *flag {
+	return &flag{
+		names:        make([]string, 0),
+		values:       make([]string, 0),
+		valuation:    valuation,
+		env:          "",
+		envFallbacks: make([]string, 0),
+		fileKey:      "",
+		finalType:    kind,
+		index:        index,
+		field:        field,
+		usage:        "",
+		group:        "",
+		deprecated:   "",
+		separator:    "",
+		explicitSep:  false,
+		envSeparator: "",
+		envIndexed:   false,
+		disableEnv:   "",
+		unique:       false,
+		trim:         false,
+		keepEmpty:    false,
+		quoted:       false,
+		greedy:       false,
+		terminal:     false,
+		appendTo:     false,
+		hidden:       false,
+		required:     false,
+		encoding:     "",
+		unit:         "",
+		isRune:       false,
+		isJSON:       false,
+		choices:      make([]string, 0),
+		match:        nil,
+		enum:         nil,
+		factories:    nil,
+		min:          reflect.Value{},
+		max:          reflect.Value{},
+		maxCount:     0,
+		maxLen:       0,
+		isSet:        false,
+		source:       fromDefault,
+		defaults:     make([]string, 0),
+		base:         reflect.Value{},
+		defaultTag:   "",
+		hasDefault:   false,
+		transforms:   make([]func(string) (string, error), 0),
+	}
 }
 
 func (f *flag) String() string {
@@ -94,49 +385,183 @@ func (f *flag) String() string {
 
 }
 
+//name returns the first name of the flag, or the name of its environment
+//variable for environment only flags, or its file key for file only flags
+func (f *flag) name() string {
+	if len(f.names) == 0 && len(f.env) == 0 {
+		return f.fileKey
+	}
+	if len(f.names) == 0 {
+		return f.env
+	}
+	return f.names[0]
+}
+
 //FlagSet is a set of flags holding parameters to populate the final data structure
-//provided
+//provided.
+//A FlagSet is configured before parsing, from a single goroutine. Parsing
+//holds a write lock and accessors (IsSet, Lookup, Args, Visit...) a read
+//lock, so that several goroutines can read a parsed FlagSet and concurrent
+//parsings are serialized.
 type FlagSet struct {
-	config interface{}
-	fmap   map[string]*flag
-	flist  []string
+	config          interface{}
+	fmap            map[string]*flag
+	flags           []*flag
+	envPrefix       string
+	autoEnv         bool
+	envUpper        bool
+	flagAsValue     bool
+	continueOnError bool
+	errs            []error
+	ignoreMissing   bool
+	anyDashes       bool
+	prefixMatch     bool
+	omitDefaults    bool
+	output          io.Writer
+	requirements    map[*flag][]*flag
+	interspersed    bool
+	args            []string
+	truthy          []string
+	falsy           []string
+	parsed          bool
+	help            *flag
+	version         *flag
+	versionString   string
+	commands        map[string]*FlagSet
+	command         string
+	commandArgs     []string
+	warnUnsetEnv    bool
+	fileValues      bool
+	stdin           bool
+	input           io.Reader
+	argsSource      func() []string
+	stdinFlag       *flag
+	stoppedBy       *flag
+	responseFiles   bool
+	expandEnv       bool
+	dryRun          bool
+	defaultSep      string
+	dotenv          map[string]string
+	ctx             context.Context
+	onSet           map[*flag][]func(values []string) error
+	mu              sync.RWMutex
 }
 
-//NewFlagSet returns a pointer to a new FlagSet or nil if an error occured.
+//NewFlagSet returns a pointer to a new FlagSet or nil if an error occured, see
+//NewFlagSetE to get the error. Every field of the structure must be exported.
 //config is a pointer to the struct to be populated with user inputs on command line
-//or using environment variables. For example:
+//or using environment variables, or nil if flags are only registered with
+//the Var methods (StringVar, IntVar...). For example:
 // type config struct {
 //	 Help bool `names:"-h,--help" usage:"prints this help message"
 //	 Targets []string `names:"-s,--server" env:"SERVERS" sep:"," usage:"server to contact"`
 // }
 //
 func NewFlagSet(config interface{}) *FlagSet {
+	fs, err := NewFlagSetE(config)
+	if err != nil {
+		return nil
+	}
+	return fs
+}
+
+//NewFlagSetE returns a pointer to a new FlagSet like NewFlagSet, or the error
+//found in the configuration structure, such as an unexported field or an
+//invalid tag. Nothing is written to the output.
+func NewFlagSetE(config interface{}) (*FlagSet, error) {
 	fs := &FlagSet{
-		config: config,
-		fmap:   make(map[string]*flag),
-		flist:  make([]string, 0),
+		config:          config,
+		fmap:            make(map[string]*flag),
+		flags:           make([]*flag, 0),
+		envPrefix:       "",
+		autoEnv:         false,
+		envUpper:        false,
+		flagAsValue:     false,
+		continueOnError: false,
+		errs:            make([]error, 0),
+		ignoreMissing:   false,
+		anyDashes:       false,
+		prefixMatch:     false,
+		omitDefaults:    false,
+		output:          os.Stderr,
+		requirements:    make(map[*flag][]*flag),
+		interspersed:    false,
+		args:            make([]string, 0),
+		truthy:          make([]string, 0),
+		falsy:           make([]string, 0),
+		parsed:          false,
+		help:            nil,
+		version:         nil,
+		versionString:   "",
+		commands:        make(map[string]*FlagSet),
+		command:         "",
+		commandArgs:     make([]string, 0),
+		warnUnsetEnv:    false,
+		fileValues:      false,
+		stdin:           false,
+		input:           os.Stdin,
+		argsSource:      nil,
+		stdinFlag:       nil,
+		stoppedBy:       nil,
+		responseFiles:   false,
+		expandEnv:       false,
+		dryRun:          false,
+		defaultSep:      "",
+		dotenv:          nil,
+		ctx:             context.Background(),
+		onSet:           make(map[*flag][]func(values []string) error),
+		mu:              sync.RWMutex{},
 	}
 
 	if err := fs.setupFlags(); err != nil {
-		return nil
+		return nil, err
 	}
-	return fs
+	return fs, nil
 }
 
 func (fs *FlagSet) setupFlags() error {
-	if reflect.TypeOf(fs.config).Kind() != reflect.Ptr {
+	if fs.config == nil {
+		return nil
+	}
+	if reflect.TypeOf(fs.config).Kind() != reflect.Ptr || reflect.TypeOf(fs.config).Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("interface provided to NewFlagSet must be a pointer to a struct")
 	}
+	if reflect.ValueOf(fs.config).IsNil() {
+		return fmt.Errorf("interface provided to NewFlagSet must not be a nil pointer")
+	}
 	t := reflect.TypeOf(fs.config).Elem()
 
 	for i := 0; i < t.NumField(); i++ {
 		ft := t.Field(i)
+		if !reflect.ValueOf(fs.config).Elem().Field(i).CanSet() {
+			return fmt.Errorf("field %s in config structure is unexported and can not be set", ft.Name)
+		}
+
+		//json:"true" is the only value of the json tag meaningful here, other
+		//values being used by encoding/json
+		isJSON := ft.Tag.Get("json") == "true"
 
-		if ft.Type.Kind() == reflect.Ptr {
-			return fmt.Errorf("pointer in config structure is not supported (%s)", ft.Name)
+		kind := ft.Type.Kind()
+		if kind == reflect.Ptr {
+			kind = ft.Type.Elem().Kind()
+			switch kind {
+			case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+				if !isJSON {
+					return fmt.Errorf("pointer to %s in config structure is not supported (%s)", kind.String(), ft.Name)
+				}
+			case reflect.Ptr, reflect.Chan, reflect.Interface, reflect.Func:
+				return fmt.Errorf("pointer to %s in config structure is not supported (%s)", kind.String(), ft.Name)
+			}
 		}
-		if ft.Type.Kind() == reflect.Map {
-			return fmt.Errorf("map in config structure is not supported (%s)", ft.Name)
+		if isJSON {
+			switch kind {
+			case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return fmt.Errorf("tag \"json\" is only supported on structs, slices, arrays and maps (%s)", ft.Name)
+			}
+		}
+		if kind == reflect.Map && !isJSON && (!scalar(ft.Type.Key()) || !scalar(ft.Type.Elem())) {
+			return fmt.Errorf("map in config structure is only supported with basic keys and values (%s)", ft.Name)
 		}
 		if ft.Type.Kind() == reflect.Chan {
 			return fmt.Errorf("chan in config structure is not supported (%s)", ft.Name)
@@ -144,28 +569,58 @@ func (fs *FlagSet) setupFlags() error {
 
 		//valuation for this flag
 		ftValuation := mono
-		if ft.Type.Kind() == reflect.Slice {
+		if kind == reflect.Slice && ft.Type.Elem().Kind() != reflect.Uint8 {
+			ftValuation = multi
+		}
+		if kind == reflect.Map || kind == reflect.Array {
 			ftValuation = multi
 		}
-		if ft.Type.Kind() == reflect.Bool {
+		if kind == reflect.Bool {
 			ftValuation = none
 		}
+		if isJSON {
+			ftValuation = mono
+		}
+		if ftValuation == multi && (kind == reflect.Slice || kind == reflect.Array) && !scalar(ft.Type.Elem()) {
+			return fmt.Errorf("%s of %s in config structure is not supported (%s)", kind.String(), ft.Type.Elem().Kind().String(), ft.Name)
+		}
+
+		flag := newFlag(reflect.ValueOf(fs.config).Elem().Field(i), i, ftValuation, kind)
+
+		if envTag, ok := ft.Tag.Lookup("env"); ok {
+			envs := strings.Split(envTag, ",")
+			for i, env := range envs {
+				env = strings.TrimSpace(env)
+				if strings.ContainsAny(env, " \t\n\r=") || (len(env) == 0 && len(envs) > 1) {
+					return fmt.Errorf("invalid environment variable name %q: a name is not empty and holds neither spaces nor equal signs (%s)", env, ft.Name)
+				}
+				if i == 0 {
+					flag.env = env
+					continue
+				}
+				flag.envFallbacks = append(flag.envFallbacks, env)
+			}
+		}
+
+		if disableTag, ok := ft.Tag.Lookup("disableenv"); ok {
+			disableTag = strings.TrimSpace(disableTag)
+			if len(disableTag) == 0 || strings.ContainsAny(disableTag, " \t\n\r=") {
+				return fmt.Errorf("invalid environment variable name %q: a name is not empty and holds neither spaces nor equal signs (%s)", disableTag, ft.Name)
+			}
+			flag.disableEnv = disableTag
+		}
 
-		flag := &flag{
-			names:     make([]string, 0),
-			values:    make([]string, 0),
-			valuation: ftValuation,
-			env:       "",
-			finalType: ft.Type.Kind(),
-			index:     i,
-			usage:     "",
-			separator: "",
-			isSet:     false,
+		if fileTag, ok := ft.Tag.Lookup("file"); ok {
+			fileTag = strings.TrimSpace(fileTag)
+			if len(fileTag) == 0 || strings.ContainsAny(fileTag, " \t\n\r=") {
+				return fmt.Errorf("invalid file key %q: a key is not empty and holds neither spaces nor equal signs (%s)", fileTag, ft.Name)
+			}
+			flag.fileKey = fileTag
 		}
 
-		// get names for this flag
+		// get names for this flag, which are optional for environment or file only flags
 		namesTag, ok := ft.Tag.Lookup("names")
-		if !ok {
+		if !ok && len(flag.env) == 0 && len(flag.fileKey) == 0 {
 			return fmt.Errorf("improper tag usage for flags: tag \"names\" is required")
 		}
 		names := strings.Split(namesTag, ",")
@@ -174,412 +629,2096 @@ func (fs *FlagSet) setupFlags() error {
 			if len(s) == 0 {
 				continue
 			}
-			flag.names = append(flag.names, s)
+			if err := checkName(s); err != nil {
+				return fmt.Errorf("%s (%s)", err, ft.Name)
+			}
+			//a name repeated in the tag is only registered once
+			repeated := false
+			for _, name := range flag.names {
+				repeated = repeated || name == s
+			}
+			if !repeated {
+				flag.names = append(flag.names, s)
+			}
 		}
-		if len(flag.names) == 0 {
+		if len(flag.names) == 0 && len(flag.env) == 0 && len(flag.fileKey) == 0 {
 			return fmt.Errorf("could not get any names tag for %s", ft.Name)
 		}
 
-		if envTag, ok := ft.Tag.Lookup("env"); ok {
-			envTag = strings.TrimSpace(envTag)
-			flag.env = envTag
-		}
-
 		if sepTag, ok := ft.Tag.Lookup("sep"); ok {
 			flag.separator = strings.TrimSpace(sepTag)
+			flag.explicitSep = true
+		}
+
+		quoted, err := boolTag(ft, "quoted")
+		if err != nil {
+			return err
+		}
+		if quoted && (ftValuation != multi || !flag.explicitSep) {
+			return fmt.Errorf("tag \"quoted\" requires a slice or a map with a sep tag (%s)", ft.Name)
+		}
+		if quoted && !quotingSeparator(flag.separator) {
+			return fmt.Errorf("tag \"quoted\" requires a single character separator, neither a quote nor a new line (%s)", ft.Name)
+		}
+		flag.quoted = quoted
+
+		if envSepTag, ok := ft.Tag.Lookup("envsep"); ok {
+			flag.envSeparator = strings.TrimSpace(envSepTag)
+			if ftValuation != multi {
+				return fmt.Errorf("tag \"envsep\" is only supported on slices and maps (%s)", ft.Name)
+			}
+			if flag.quoted && !quotingSeparator(flag.envSeparator) {
+				return fmt.Errorf("tag \"quoted\" requires a single character separator, neither a quote nor a new line (%s)", ft.Name)
+			}
+		}
+
+		envIndexed, err := boolTag(ft, "envindexed")
+		if err != nil {
+			return err
+		}
+		flag.envIndexed = envIndexed
+		if envIndexed && ftValuation != multi {
+			return fmt.Errorf("tag \"envindexed\" is only supported on slices and maps (%s)", ft.Name)
 		}
 
 		if usageTag, ok := ft.Tag.Lookup("usage"); ok {
 			flag.usage = strings.TrimSpace(usageTag)
 		}
 
+		if groupTag, ok := ft.Tag.Lookup("group"); ok {
+			flag.group = strings.TrimSpace(groupTag)
+		}
+
+		if deprecatedTag, ok := ft.Tag.Lookup("deprecated"); ok {
+			flag.deprecated = strings.TrimSpace(deprecatedTag)
+			if len(flag.deprecated) == 0 {
+				return fmt.Errorf("tag \"deprecated\" requires a message (%s)", ft.Name)
+			}
+		}
+
+		unique, err := boolTag(ft, "unique")
+		if err != nil {
+			return err
+		}
+		if unique && ftValuation != multi {
+			return fmt.Errorf("tag \"unique\" is only supported on slices (%s)", ft.Name)
+		}
+		flag.unique = unique
+
+		trim, err := boolTag(ft, "trim")
+		if err != nil {
+			return err
+		}
+		if trim && ftValuation != multi {
+			return fmt.Errorf("tag \"trim\" is only supported on slices (%s)", ft.Name)
+		}
+		flag.trim = trim
+
+		keepEmpty, err := boolTag(ft, "keepempty")
+		if err != nil {
+			return err
+		}
+		if keepEmpty && ftValuation != multi {
+			return fmt.Errorf("tag \"keepempty\" is only supported on slices (%s)", ft.Name)
+		}
+		flag.keepEmpty = keepEmpty
+
+		greedy, err := boolTag(ft, "greedy")
+		if err != nil {
+			return err
+		}
+		if greedy && ftValuation != multi {
+			return fmt.Errorf("tag \"greedy\" is only supported on slices and maps (%s)", ft.Name)
+		}
+		flag.greedy = greedy
+
+		if flag.terminal, err = boolTag(ft, "terminal"); err != nil {
+			return err
+		}
+		if flag.terminal && len(flag.names) == 0 {
+			return fmt.Errorf("tag \"terminal\" requires a names tag (%s)", ft.Name)
+		}
+
+		appendTo, err := boolTag(ft, "append")
+		if err != nil {
+			return err
+		}
+		if appendTo && (ftValuation != multi || kind != reflect.Slice) {
+			return fmt.Errorf("tag \"append\" is only supported on slices (%s)", ft.Name)
+		}
+		flag.appendTo = appendTo
+
+		if flag.hidden, err = boolTag(ft, "hidden"); err != nil {
+			return err
+		}
+
+		if flag.required, err = boolTag(ft, "required"); err != nil {
+			return err
+		}
+		if _, ok := ft.Tag.Lookup("default"); ok && flag.required {
+			return fmt.Errorf("tags \"required\" and \"default\" can not be used together (%s)", ft.Name)
+		}
+
+		help, err := boolTag(ft, "help")
+		if err != nil {
+			return err
+		}
+		if help && (ftValuation != none || len(flag.names) == 0) {
+			return fmt.Errorf("tag \"help\" is only supported on booleans with names (%s)", ft.Name)
+		}
+		if help && fs.help != nil {
+			return fmt.Errorf("tag \"help\" is set on several fields (%s)", ft.Name)
+		}
+		if help {
+			fs.help = flag
+		}
+
+		version, err := boolTag(ft, "version")
+		if err != nil {
+			return err
+		}
+		if version && (ftValuation != none || len(flag.names) == 0) {
+			return fmt.Errorf("tag \"version\" is only supported on booleans with names (%s)", ft.Name)
+		}
+		if version && fs.version != nil {
+			return fmt.Errorf("tag \"version\" is set on several fields (%s)", ft.Name)
+		}
+		if version {
+			fs.version = flag
+		}
+
+		isRune, err := boolTag(ft, "rune")
+		if err != nil {
+			return err
+		}
+		if isRune && elemKind(ft.Type) != reflect.Int32 {
+			return fmt.Errorf("tag \"rune\" is only supported on rune or int32 (%s)", ft.Name)
+		}
+		flag.isRune = isRune
+		flag.isJSON = isJSON
+
+		if encodingTag, ok := ft.Tag.Lookup("encoding"); ok {
+			flag.encoding = strings.TrimSpace(encodingTag)
+			if kind != reflect.Slice || ftValuation != mono {
+				return fmt.Errorf("tag \"encoding\" is only supported on []byte (%s)", ft.Name)
+			}
+			if flag.encoding != "base64" && flag.encoding != "hex" {
+				return fmt.Errorf("tag \"encoding\" must be base64 or hex (%s)", ft.Name)
+			}
+		}
+
+		if unitTag, ok := ft.Tag.Lookup("unit"); ok {
+			flag.unit = strings.TrimSpace(unitTag)
+			if flag.unit != "bytes" {
+				return fmt.Errorf("tag \"unit\" must be bytes (%s)", ft.Name)
+			}
+			t := ft.Type
+			if t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return fmt.Errorf("tag \"unit\" is only supported on integers (%s)", ft.Name)
+			}
+			if _, ok := ft.Tag.Lookup("enum"); ok || t == durationType {
+				return fmt.Errorf("tag \"unit\" is only supported on integers (%s)", ft.Name)
+			}
+		}
+
+		if choicesTag, ok := ft.Tag.Lookup("choices"); ok {
+			for _, c := range strings.Split(choicesTag, ",") {
+				c = strings.TrimSpace(c)
+				if len(c) == 0 {
+					continue
+				}
+				flag.choices = append(flag.choices, c)
+			}
+			if len(flag.choices) == 0 {
+				return fmt.Errorf("tag \"choices\" does not hold any value (%s)", ft.Name)
+			}
+		}
+
+		if enumTag, ok := ft.Tag.Lookup("enum"); ok {
+			t := ft.Type
+			if t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+			if flag.enum, err = parseEnum(enumTag, t); err != nil {
+				return fmt.Errorf("tag \"enum\" %s (%s)", err, ft.Name)
+			}
+		}
+
+		if matchTag, ok := ft.Tag.Lookup("match"); ok {
+			if flag.match, err = regexp.Compile(matchTag); err != nil {
+				return fmt.Errorf("tag \"match\" is not a valid regular expression (%s): %w", ft.Name, err)
+			}
+		}
+
+		if maxLenTag, ok := ft.Tag.Lookup("maxlen"); ok {
+			if elemKind(ft.Type) != reflect.String {
+				return fmt.Errorf("tag \"maxlen\" is only supported on strings (%s)", ft.Name)
+			}
+			maxLen, err := strconv.Atoi(strings.TrimSpace(maxLenTag))
+			if err != nil || maxLen < 1 {
+				return fmt.Errorf("tag \"maxlen\" must be a positive integer (%s)", ft.Name)
+			}
+			flag.maxLen = maxLen
+		}
+
+		if flag.min, err = boundTag(ft, "min"); err != nil {
+			return err
+		}
+		if flag.max, err = boundTag(ft, "max"); err != nil {
+			return err
+		}
+
+		if maxCountTag, ok := ft.Tag.Lookup("max-count"); ok {
+			if ftValuation != multi {
+				return fmt.Errorf("tag \"max-count\" is only supported on slices and maps (%s)", ft.Name)
+			}
+			maxCount, err := strconv.Atoi(strings.TrimSpace(maxCountTag))
+			if err != nil || maxCount < 1 {
+				return fmt.Errorf("tag \"max-count\" must be a positive integer (%s)", ft.Name)
+			}
+			flag.maxCount = maxCount
+		}
+
+		flag.defaults = flag.fieldValues(flag.field)
+		if flag.appendTo {
+			field := flag.field
+			flag.base = reflect.MakeSlice(field.Type(), 0, field.Len())
+			flag.base = reflect.AppendSlice(flag.base, field)
+		}
+
+		if defaultTag, ok := ft.Tag.Lookup("default"); ok {
+			flag.defaultTag = defaultTag
+			flag.hasDefault = true
+			values, err := flag.defaultValues()
+			if err != nil {
+				return fmt.Errorf("tag \"default\" holds an invalid value (%s): %w", ft.Name, err)
+			}
+			flag.values = values
+			field := reflect.New(ft.Type).Elem()
+			err = flag.setField(field)
+			flag.values = make([]string, 0)
+			if err != nil {
+				return fmt.Errorf("tag \"default\" holds an invalid value (%s): %w", ft.Name, err)
+			}
+			flag.defaults = flag.fieldValues(field)
+		}
+
 		for _, name := range flag.names {
 			fs.fmap[name] = flag
 		}
-		fs.flist = append(fs.flist, flag.names[0])
+		fs.flags = append(fs.flags, flag)
 	}
 	return nil
 }
 
-//Parse parse command line and populate provided configuration structure
-func (fs *FlagSet) Parse() error {
+//SetEnvPrefix sets a prefix prepended to every environment variable name looked
+//up, separated with an underscore. For example, with prefix "MYAPP", a flag
+//tagged env:"SERVERS" is set using MYAPP_SERVERS. An empty prefix (the default)
+//leaves environment variable names untouched.
+func (fs *FlagSet) SetEnvPrefix(prefix string) {
+	fs.envPrefix = strings.TrimSpace(prefix)
+}
 
-	if err := fs.parseCommand(os.Args[1:]); err != nil {
-		return fmt.Errorf("could not parse commande line: %s", err)
-	}
+//AutoEnv enables or disables environment variable names derived from flag names
+//for flags without an env tag. The name is built from the first long name of
+//the flag (or its first name if it has no long name), stripping leading dashes,
+//upper casing it and replacing dashes with underscores: --long-is-long is set
+//using LONG_IS_LONG. An explicit env tag always takes precedence over the
+//derived name. The prefix set with SetEnvPrefix applies to derived names too.
+func (fs *FlagSet) AutoEnv(enable bool) {
+	fs.autoEnv = enable
+}
 
-	if err := fs.parseEnv(); err != nil {
-		return fmt.Errorf("could not get values from environment variables: %s", err)
-	}
+//EnvUpper sets whether environment variable names are upper cased before being
+//looked up, prefix included: with env:"servers", SERVERS is looked up
+func (fs *FlagSet) EnvUpper(enable bool) {
+	fs.envUpper = enable
+}
 
-	if err := fs.setConfig(); err != nil {
-		return fmt.Errorf("could not populate data structure: %s", err)
+//envName returns the name of the environment variable to look up for fitem,
+//or an empty string if none applies
+func (fs *FlagSet) envName(fitem *flag) string {
+	env := fitem.env
+	if len(env) == 0 && fs.autoEnv && len(fitem.names) != 0 {
+		env = deriveEnv(fitem.names)
 	}
-
-	return nil
+	if len(env) == 0 {
+		return ""
+	}
+	return fs.envVariable(env)
 }
 
-func (fs *FlagSet) parseCommand(args []string) error {
-	if len(args) == 0 {
-		return nil
+//envNames returns the names of the environment variables to look up for
+//fitem, in order: its environment variable and the fallbacks of its env tag
+func (fs *FlagSet) envNames(fitem *flag) []string {
+	names := make([]string, 0, 1+len(fitem.envFallbacks))
+	if env := fs.envName(fitem); len(env) != 0 {
+		names = append(names, env)
 	}
-
-	arg := args[0]
-	fitem, ok := fs.fmap[arg]
-	if !ok {
-		return fmt.Errorf("%s is not a valid flag", arg)
+	for _, env := range fitem.envFallbacks {
+		names = append(names, fs.envVariable(env))
 	}
+	return names
+}
 
-	//boolean flag (valuation == none)
-	if fs.fmap[arg].finalType == reflect.Bool {
-		fs.fmap[arg].isSet = true
-		return fs.parseCommand(args[1:])
+//envVariable returns the name of the environment variable env, prefixed and
+//upper cased if required
+func (fs *FlagSet) envVariable(env string) string {
+	if len(fs.envPrefix) != 0 {
+		env = fs.envPrefix + "_" + env
 	}
-
-	if len(args) < 2 {
-		return fmt.Errorf("missing value for flag %s", arg)
+	if fs.envUpper {
+		env = strings.ToUpper(env)
 	}
-	values := args[1]
+	return env
+}
 
-	//mono flag (valuation == mono)
-	if fitem.valuation == mono && fitem.isSet {
-		return fmt.Errorf("flag %s already set", arg)
+//envValue returns the name and the value of the first environment variable
+//of fitem set or, if none is, of its entry in the file given to ParseReader
+func (fs *FlagSet) envValue(fitem *flag) (string, string) {
+	env := fs.envName(fitem)
+	envs := fs.envNames(fitem)
+	for _, name := range envs {
+		if value := os.Getenv(name); len(value) != 0 {
+			return name, value
+		}
 	}
-
-	if fitem.valuation == mono {
-		fitem.values = append(fitem.values, values)
-		fitem.isSet = true
-		return fs.parseCommand(args[2:])
+	for _, key := range append([]string{fitem.fileKey}, envs...) {
+		if len(key) == 0 {
+			continue
+		}
+		if value := fs.dotenv[key]; len(value) != 0 {
+			return key, value
+		}
 	}
+	return env, ""
+}
 
-	//multi flag (valuation == multi)
-	if len(fitem.separator) != 0 {
-		splitted := strings.Split(values, fitem.separator)
-		found := false
-		for _, v := range splitted {
-			if len(strings.TrimSpace(v)) != 0 {
-				fitem.values = append(fitem.values, v)
-				found = true
-				fitem.isSet = true
-			}
+//indexedValues returns the values of the environment variables env_0, env_1...
+//up to the first one not set, read from the file given to ParseReader if not
+//set in the environment
+func (fs *FlagSet) indexedValues(env string) []string {
+	values := make([]string, 0)
+	for i := 0; ; i++ {
+		name := env + "_" + strconv.Itoa(i)
+		value := os.Getenv(name)
+		if len(value) == 0 {
+			value = fs.dotenv[name]
 		}
-		if !found {
-			return fmt.Errorf("missing value for flag %s", arg)
+		if len(value) == 0 {
+			return values
 		}
-	} else {
-		fitem.values = append(fitem.values, values)
-		fitem.isSet = true
+		values = append(values, value)
 	}
-	return fs.parseCommand(args[2:])
 }
 
-func (fs *FlagSet) parseEnv() error {
+//deriveEnv returns an environment variable name built from the first long
+//name in names
+func deriveEnv(names []string) string {
+	name := strings.TrimLeft(longName(names), "-")
+	return strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
 
-	for _, fname := range fs.flist {
-		fitem := fs.fmap[fname]
-		if fitem.isSet || len(fitem.env) == 0 {
-			continue
+//longName returns the first name starting with a double dash in names, the
+//first name if there is none, or an empty string if names is empty
+func longName(names []string) string {
+	for _, n := range names {
+		if strings.HasPrefix(n, "--") {
+			return n
 		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
 
-		values := os.Getenv(fitem.env)
-		if len(values) == 0 {
-			continue
-		}
+//ContinueOnError enables or disables errors collection. When enabled, Parse does
+//not stop at the first error: the configuration structure is populated with
+//every value that could be parsed and all errors encountered are returned
+//joined together (see errors.Join).
+func (fs *FlagSet) ContinueOnError(enable bool) {
+	fs.continueOnError = enable
+}
 
-		if fitem.valuation == none {
-			fitem.isSet = true
-			continue
-		}
+//AllowFlagAsValue allows or forbids (the default) a registered flag name to be
+//used as the value of the flag preceding it. When forbidden, "-i -v" with -i
+//expecting a value and -v being a registered flag fails with ErrMissingValue
+//instead of setting -i to "-v". Values looking like flags but not registered
+//(-5 for example) are always accepted.
+func (fs *FlagSet) AllowFlagAsValue(allow bool) {
+	fs.flagAsValue = allow
+}
 
-		if fitem.valuation == mono {
-			fitem.values = append(fitem.values, values)
-			fitem.isSet = true
+//ExpandEnv sets whether ${VAR} and $VAR in values given on the command line or
+//from a file are replaced with os.ExpandEnv, an unset variable being replaced
+//with an empty string. For example, --path ${HOME}/data is given the data
+//directory of the user. Values of environment variables are not expanded, to
+//avoid expanding a value twice.
+func (fs *FlagSet) ExpandEnv(expand bool) {
+	fs.expandEnv = expand
+}
+
+//WarnUnsetEnv sets whether parsing writes to the output a warning for every
+//flag bound to an environment variable which ends up unset from all sources,
+//naming the variable looked for. It helps spotting a misspelled env tag.
+func (fs *FlagSet) WarnUnsetEnv(warn bool) {
+	fs.warnUnsetEnv = warn
+}
+
+//warnUnset writes a warning for every flag bound to an environment variable
+//and given no value
+func (fs *FlagSet) warnUnset() {
+	for _, fitem := range fs.flags {
+		names := fs.envNames(fitem)
+		if fitem.isSet || len(names) == 0 {
 			continue
 		}
-
-		if len(fitem.separator) != 0 {
-			splitted := strings.Split(values, fitem.separator)
-			for _, v := range splitted {
-				if len(strings.TrimSpace(v)) != 0 {
-					fitem.values = append(fitem.values, v)
-					fitem.isSet = true
-				}
-			}
+		if len(names) == 1 {
+			fmt.Fprintf(fs.output, "warning: no value for %s, environment variable %s is not set\n", fitem.name(), names[0])
+			continue
 		}
+		fmt.Fprintf(fs.output, "warning: no value for %s, none of the environment variables %s is set\n", fitem.name(), strings.Join(names, ", "))
+	}
+}
 
-		if len(fitem.values) == 0 {
-			fitem.values = append(fitem.values, values)
-			fitem.isSet = true
-		}
+//SetOutput sets the destination of every message written by the FlagSet,
+//os.Stderr by default: usage, version, deprecation and unset environment
+//variable warnings, and diagnostics. Messages are discarded if w is nil or
+//io.Discard.
+func (fs *FlagSet) SetOutput(w io.Writer) {
+	if w == nil {
+		w = io.Discard
 	}
+	fs.output = w
+}
 
-	return nil
+//Output returns the destination of usage and warning messages
+func (fs *FlagSet) Output() io.Writer {
+	return fs.output
 }
 
-func (fs *FlagSet) setConfig() error {
-	if !reflect.ValueOf(fs.config).Elem().Field(0).CanAddr() {
-		fmt.Printf("can not addr fs.config field(0)\n")
+//SetBoolStrings sets the strings accepted as true and false for boolean flags
+//and the elements of boolean slices, compared regardless of case, on the
+//command line (--flag=value), in environment variables and files. Any other
+//string is an error. If both truthy and falsy are empty (the default), the
+//strings accepted by strconv.ParseBool are used.
+func (fs *FlagSet) SetBoolStrings(truthy, falsy []string) {
+	fs.truthy = append([]string{}, truthy...)
+	fs.falsy = append([]string{}, falsy...)
+}
+
+//parseBool returns the boolean value of s
+func (fs *FlagSet) parseBool(s string) (bool, error) {
+	if len(fs.truthy) == 0 && len(fs.falsy) == 0 {
+		return strconv.ParseBool(s)
 	}
-	if !reflect.ValueOf(fs.config).Elem().Field(0).IsValid() {
-		fmt.Printf("not valid fs.config field(0)\n")
+	for _, t := range fs.truthy {
+		if strings.EqualFold(s, t) {
+			return true, nil
+		}
 	}
-	if !reflect.ValueOf(fs.config).Elem().Field(0).CanSet() {
-		fmt.Printf("can not set fs.config field(0)\n")
+	for _, f := range fs.falsy {
+		if strings.EqualFold(s, f) {
+			return false, nil
+		}
 	}
+	return false, fmt.Errorf("%q is not a boolean", s)
+}
 
-	for _, fname := range fs.flist {
-		fitem := fs.fmap[fname]
-		if !fitem.isSet {
-			continue
-		}
+//NormalizeDashes enables or disables matching flags regardless of the number of
+//leading dashes on the command line, like the standard library does: a flag
+//declared as --server can then be set with -server and vice versa. Names
+//declared verbatim always take precedence.
+func (fs *FlagSet) NormalizeDashes(enable bool) {
+	fs.anyDashes = enable
+}
 
-		ith := reflect.ValueOf(fs.config).Elem().Field(fitem.index)
-		if fitem.valuation == none {
-			ith.SetBool(true)
-			continue
-		}
+//AllowPrefixMatch enables or disables matching long flags (starting with a
+//double dash) with an unambiguous prefix of their name: if --timeout is the
+//only flag starting with --time, --time sets --timeout. If several flags
+//match, an error listing the candidates is returned.
+func (fs *FlagSet) AllowPrefixMatch(allow bool) {
+	fs.prefixMatch = allow
+}
 
-		if fitem.valuation == mono {
-			switch fitem.finalType {
-			case reflect.String:
-				ith.SetString(fitem.values[0])
-				continue
-			case reflect.Uint:
-				v, err := strconv.ParseUint(fitem.values[0], 10, 0)
-				if err != nil {
-					return err
-				}
-				ith.SetUint(v)
-				continue
-			case reflect.Uint8:
-				v, err := strconv.ParseUint(fitem.values[0], 10, 8)
-				if err != nil {
-					return err
-				}
-				ith.SetUint(v)
-				continue
-			case reflect.Uint16:
-				v, err := strconv.ParseUint(fitem.values[0], 10, 16)
-				if err != nil {
-					return err
-				}
-				ith.SetUint(v)
-				continue
-			case reflect.Uint32:
-				v, err := strconv.ParseUint(fitem.values[0], 10, 32)
-				if err != nil {
-					return err
-				}
-				ith.SetUint(v)
-				continue
-			case reflect.Uint64:
-				v, err := strconv.ParseUint(fitem.values[0], 10, 64)
-				if err != nil {
-					return err
-				}
-				ith.SetUint(v)
-				continue
-			case reflect.Int:
-				v, err := strconv.ParseInt(fitem.values[0], 10, 0)
-				if err != nil {
-					return err
-				}
-				ith.SetInt(v)
-				continue
-			case reflect.Int8:
-				v, err := strconv.ParseInt(fitem.values[0], 10, 8)
-				if err != nil {
-					return err
-				}
-				ith.SetInt(v)
-				continue
-			case reflect.Int16:
-				v, err := strconv.ParseInt(fitem.values[0], 10, 16)
-				if err != nil {
-					return err
-				}
-				ith.SetInt(v)
-				continue
-			case reflect.Int32:
-				v, err := strconv.ParseInt(fitem.values[0], 10, 32)
-				if err != nil {
-					return err
-				}
-				ith.SetInt(v)
-				continue
-			case reflect.Int64:
-				v, err := strconv.ParseInt(fitem.values[0], 10, 64)
-				if err != nil {
-					return err
-				}
-				ith.SetInt(v)
-				continue
-			case reflect.Float32:
-				v, err := strconv.ParseFloat(fitem.values[0], 32)
-				if err != nil {
-					return err
-				}
-				ith.SetFloat(v)
-				continue
-			case reflect.Float64:
-				v, err := strconv.ParseFloat(fitem.values[0], 64)
-				if err != nil {
-					return err
-				}
-				ith.SetFloat(v)
-				continue
-			default:
-				return fmt.Errorf("can not guess type: %s", fitem.finalType.String())
+//lookup returns the flag registered for name as found on the command line
+func (fs *FlagSet) lookup(name string) (*flag, error) {
+	if fitem, ok := fs.fmap[name]; ok {
+		return fitem, nil
+	}
+	if fs.anyDashes && strings.HasPrefix(name, "--") {
+		if fitem, ok := fs.fmap[name[1:]]; ok {
+			return fitem, nil
+		}
+	} else if fs.anyDashes && strings.HasPrefix(name, "-") {
+		if fitem, ok := fs.fmap["-"+name]; ok {
+			return fitem, nil
+		}
+	}
+	if fs.prefixMatch && strings.HasPrefix(name, "--") && len(name) > 2 {
+		return fs.lookupPrefix(name)
+	}
+	return nil, fmt.Errorf("%w %s", ErrUnknownFlag, name)
+}
+
+//isFlag reports whether arg, following a flag expecting a value, is a flag
+//rather than the value. A numeric token such as -10 is a value unless it is
+//registered as is.
+func (fs *FlagSet) isFlag(arg string) bool {
+	if _, ok := fs.fmap[arg]; ok {
+		return true
+	}
+	if _, err := strconv.ParseFloat(arg, 64); err == nil {
+		return false
+	}
+	_, err := fs.lookup(arg)
+	return err == nil
+}
+
+//lookupPrefix returns the only flag with a long name starting with prefix
+func (fs *FlagSet) lookupPrefix(prefix string) (*flag, error) {
+	var found *flag
+	candidates := make([]string, 0)
+	for _, fitem := range fs.flags {
+		for _, n := range fitem.names {
+			if strings.HasPrefix(n, "--") && strings.HasPrefix(n, prefix) {
+				found = fitem
+				candidates = append(candidates, n)
+				break
 			}
 		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrUnknownFlag, prefix)
+	}
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("%w %s (candidates: %s)", ErrAmbiguousFlag, prefix, strings.Join(candidates, ", "))
+	}
+	return found, nil
+}
 
-		if fitem.valuation == multi {
-			newSlice := reflect.MakeSlice(ith.Type(), 0, 0)
+//fail returns err, or records it and returns nil if errors are collected
+func (fs *FlagSet) fail(err error) error {
+	if !fs.continueOnError {
+		return err
+	}
+	fs.errs = append(fs.errs, err)
+	return nil
+}
 
-			switch ith.Type().Elem().Kind() {
-			case reflect.String:
-				for _, vstr := range fitem.values {
-					rv := reflect.ValueOf(vstr)
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Uint:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseUint(vstr, 10, 0)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(uint(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Uint8:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseUint(vstr, 10, 8)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(uint8(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Uint16:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseUint(vstr, 10, 16)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(uint16(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Uint32:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseUint(vstr, 10, 32)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(uint32(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Uint64:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseUint(vstr, 10, 64)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(uint64(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Int:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseInt(vstr, 10, 0)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(int(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Int8:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseInt(vstr, 10, 8)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(int8(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Int16:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseInt(vstr, 10, 16)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(int16(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Int32:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseInt(vstr, 10, 32)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(int32(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Int64:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseInt(vstr, 10, 64)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(int64(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Float32:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseFloat(vstr, 32)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(float32(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
-				continue
-			case reflect.Float64:
-				for _, vstr := range fitem.values {
-					v, err := strconv.ParseFloat(vstr, 64)
-					if err != nil {
-						return err
-					}
-					rv := reflect.ValueOf(float64(v))
-					newSlice = reflect.Append(newSlice, rv)
-				}
-				ith.Set(newSlice)
+//SetDefaultSeparator sets the separator of the multivaluated flags without a
+//sep tag, on the command line and for environment variables (unless an envsep
+//tag is set), including the ones registered afterwards. It is empty by
+//default: values are not split.
+func (fs *FlagSet) SetDefaultSeparator(sep string) {
+	fs.defaultSep = sep
+	for _, fitem := range fs.flags {
+		if fitem.valuation == multi && !fitem.explicitSep {
+			fitem.separator = sep
+		}
+	}
+}
+
+//Parse parse command line and populate provided configuration structure
+func (fs *FlagSet) Parse() error {
+	return fs.ParseArgs(fs.commandLine())
+}
+
+//SetArgsSource sets the function returning the command line parsed by Parse,
+//ParseWithFile and ParseReader, without the program name. If fn is nil (the
+//default), os.Args[1:] is parsed.
+func (fs *FlagSet) SetArgsSource(fn func() []string) {
+	fs.argsSource = fn
+}
+
+//commandLine returns the arguments given by the function set with
+//SetArgsSource, or os.Args[1:]
+func (fs *FlagSet) commandLine() []string {
+	if fs.argsSource == nil {
+		return os.Args[1:]
+	}
+	return fs.argsSource()
+}
+
+//ParseArgs parses args, which must not include the program name, as the command
+//line and populates provided configuration structure
+func (fs *FlagSet) ParseArgs(args []string) error {
+	return fs.parse(args, "")
+}
+
+//ParseContext parses args like ParseArgs, stopping with the error of ctx once
+//it is done. ctx is checked before each argument and before each file is read,
+//files given as values with AllowFileValues and response files included, and
+//is passed on to the subcommand.
+func (fs *FlagSet) ParseContext(ctx context.Context, args []string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.ctx = ctx
+	defer func() { fs.ctx = context.Background() }()
+	return fs.run(args, "")
+}
+
+//ParseArgsRemaining parses args like ParseArgs and returns the positional
+//arguments left, every argument after "--" included. It is meant for a parser
+//handling global flags to pass the remaining arguments to another one.
+func (fs *FlagSet) ParseArgsRemaining(args []string) ([]string, error) {
+	err := fs.ParseArgs(args)
+	return fs.Args(), err
+}
+
+//parse populates provided configuration structure from args, environment
+//variables and the file at path if not empty, in this order of precedence
+func (fs *FlagSet) parse(args []string, path string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.run(args, path)
+}
+
+//run parses args, environment variables and the file at path, fs being locked
+func (fs *FlagSet) run(args []string, path string) error {
+	fs.errs = make([]error, 0)
+	fs.args = make([]string, 0)
+	fs.command = ""
+	fs.commandArgs = make([]string, 0)
+	fs.stdinFlag = nil
+	fs.stoppedBy = nil
+	fs.parsed = true
+	//values of a flag not set come from a previous parsing of its default tag
+	//and would be read before the ones given now
+	for _, fitem := range fs.flags {
+		if !fitem.isSet {
+			fitem.values = make([]string, 0)
+		}
+	}
+
+	if fs.responseFiles {
+		expanded, err := expandResponseFiles(fs.ctx, args, make([]string, 0))
+		if err != nil {
+			return fmt.Errorf("could not parse command line: %w", err)
+		}
+		args = expanded
+	}
+
+	//a validation reports the request without writing anything
+	if fs.requested(fs.help, args) {
+		if !fs.dryRun {
+			fs.Usage()
+		}
+		return ErrHelp
+	}
+	if fs.requested(fs.version, args) {
+		if !fs.dryRun {
+			fmt.Fprintln(fs.output, fs.versionString)
+		}
+		return ErrVersion
+	}
+
+	if err := fs.parseCommand(args); err != nil {
+		if errors.Is(err, ErrTerminal) {
+			fs.setSource(fromCommandLine)
+			if err := fs.setField(fs.stoppedBy); err != nil {
+				return fmt.Errorf("could not populate data structure: %w", err)
+			}
+			return ErrTerminal
+		}
+		return fmt.Errorf("could not parse command line: %w", err)
+	}
+	fs.setSource(fromCommandLine)
+
+	if err := fs.parseEnv(); err != nil {
+		return fmt.Errorf("could not get values from environment variables: %w", err)
+	}
+	if err := fs.fireUnsourced(); err != nil {
+		return fmt.Errorf("could not get values from environment variables: %w", err)
+	}
+	fs.setSource(fromEnv)
+
+	if len(path) != 0 {
+		if err := fs.ctx.Err(); err != nil {
+			return fmt.Errorf("could not get values from file %s: %w", path, err)
+		}
+		if err := fs.parseFile(path); err != nil {
+			return fmt.Errorf("could not get values from file %s: %w", path, err)
+		}
+		if err := fs.fireUnsourced(); err != nil {
+			return fmt.Errorf("could not get values from file %s: %w", path, err)
+		}
+		fs.setSource(fromFile)
+	}
+
+	if err := fs.parseDefaults(); err != nil {
+		return fmt.Errorf("could not get default values: %w", err)
+	}
+
+	//like usage and version, warnings are not written by a validation
+	if fs.warnUnsetEnv && !fs.dryRun {
+		fs.warnUnset()
+	}
+
+	if err := fs.checkRequirements(); err != nil {
+		return err
+	}
+
+	if err := fs.setConfig(); err != nil {
+		return fmt.Errorf("could not populate data structure: %w", err)
+	}
+
+	if len(fs.command) != 0 {
+		sub := fs.commands[fs.command]
+		ctx := fs.ctx
+		parseSub := func(args []string) error { return sub.ParseContext(ctx, args) }
+		if fs.dryRun {
+			parseSub = sub.Validate
+		}
+		if err := parseSub(fs.commandArgs); err != nil {
+			return errors.Join(append(fs.errs, fmt.Errorf("%s: %w", fs.command, err))...)
+		}
+	}
+
+	return errors.Join(fs.errs...)
+}
+
+//Requires declares that the flag name can only be set if the flags required
+//are set too, whatever the source (command line, environment variables or
+//file). For example, fs.Requires("--tls-cert", "--tls-key") makes parsing fail
+//if --tls-cert is set but --tls-key is not.
+func (fs *FlagSet) Requires(name string, required ...string) error {
+	fitem, ok := fs.fmap[name]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFlag, name)
+	}
+	for _, r := range required {
+		ritem, ok := fs.fmap[r]
+		if !ok {
+			return fmt.Errorf("%w %s", ErrUnknownFlag, r)
+		}
+		fs.requirements[fitem] = append(fs.requirements[fitem], ritem)
+	}
+	return nil
+}
+
+//checkRequirements makes sure the flags required by the flags set are set
+func (fs *FlagSet) checkRequirements() error {
+	for _, fitem := range fs.flags {
+		if !fitem.isSet && fitem.required {
+			if err := fs.fail(fmt.Errorf("%w %s", ErrRequiredFlag, fitem.name())); err != nil {
+				return err
+			}
+		}
+		if !fitem.isSet {
+			continue
+		}
+		for _, ritem := range fs.requirements[fitem] {
+			if ritem.isSet {
 				continue
-			default:
-				return fmt.Errorf("can not guess type: %s", fitem.finalType.String())
 			}
+			err := fmt.Errorf("%w %s (required by %s)", ErrRequiredFlag, ritem.name(), fitem.name())
+			if err := fs.fail(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//MissingRequired returns the first name of each flag with the required tag
+//which is not set, in the order the flags are declared. Once a parsing
+//continuing on errors is done, it tells which values are still to be
+//requested from the user.
+func (fs *FlagSet) MissingRequired() []string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	names := make([]string, 0)
+	for _, fitem := range fs.flags {
+		if fitem.required && !fitem.isSet {
+			names = append(names, fitem.name())
+		}
+	}
+	return names
+}
+
+//SetVersion sets the version written when the version flag is set
+func (fs *FlagSet) SetVersion(version string) {
+	fs.versionString = version
+}
+
+//requested reports whether fitem, the help or version flag, is set in args,
+//whatever the other arguments are. The arguments following a subcommand are
+//left to the subcommand.
+func (fs *FlagSet) requested(fitem *flag, args []string) bool {
+	if fitem == nil {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if _, ok := fs.commands[arg]; ok {
+			return false
+		}
+		name, value, inline := arg, "", false
+		if i := strings.Index(arg, "="); i > 0 {
+			name, value, inline = arg[:i], arg[i+1:], true
+		}
+		if found, err := fs.lookup(name); err != nil || found != fitem {
+			continue
+		}
+		if !inline {
+			return true
+		}
+		if b, err := fs.parseBool(value); err == nil && b {
+			return true
+		}
+	}
+	return false
+}
+
+//OnSet registers fn to be called during parsing when the flag registered as
+//name is set. fn is given the values of each occurrence of the flag on the
+//command line, in the command line order, and the values from the environment
+//variable or the file once the command line is parsed. fn is called by Set as
+//well, but not for values from the default tag. An error returned by fn aborts
+//parsing, whether errors are collected or not. fn must not call the methods of
+//fs, which is locked during parsing.
+func (fs *FlagSet) OnSet(name string, fn func(values []string) error) error {
+	fitem, ok := fs.fmap[name]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFlag, name)
+	}
+	fs.onSet[fitem] = append(fs.onSet[fitem], fn)
+	return nil
+}
+
+//Transform registers fn to normalize the values of the flag registered as
+//name before they are converted, whatever their source: each value of a
+//multivaluated flag or the value of a monovaluated flag is replaced with the
+//result of fn, for example strings.ToLower or filepath.Abs. Functions are
+//called in the order they are registered. An error returned by fn is a
+//parsing error naming the flag.
+func (fs *FlagSet) Transform(name string, fn func(string) (string, error)) error {
+	fitem, ok := fs.fmap[name]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFlag, name)
+	}
+	if fitem.valuation == none {
+		return fmt.Errorf("boolean flag %s can not be transformed", name)
+	}
+	fitem.transforms = append(fitem.transforms, fn)
+	return nil
+}
+
+//RegisterFactory sets the factories building the value of the flag registered
+//as name, which must be bound to an interface field: the value given to the
+//flag selects the factory called. For example, with
+// fs.RegisterFactory("--backend", map[string]func() interface{}{"s3": newS3, "gcs": newGCS})
+//--backend s3 sets the field to the value returned by newS3. A value matching
+//no factory is an error.
+func (fs *FlagSet) RegisterFactory(name string, factories map[string]func() interface{}) error {
+	fitem, ok := fs.fmap[name]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownFlag, name)
+	}
+	if fitem.field.Kind() != reflect.Interface {
+		return fmt.Errorf("flag %s is not bound to an interface", name)
+	}
+	if len(factories) == 0 {
+		return fmt.Errorf("no factory given for %s", name)
+	}
+	fitem.factories = make(map[string]func() interface{}, len(factories))
+	for k, factory := range factories {
+		fitem.factories[k] = factory
+	}
+	return nil
+}
+
+//fire calls the functions registered with OnSet for fitem, given values
+func (fs *FlagSet) fire(fitem *flag, values []string) error {
+	for _, fn := range fs.onSet[fitem] {
+		if err := fn(append([]string{}, values...)); err != nil {
+			return fmt.Errorf("flag %s: %w", fitem.name(), err)
+		}
+	}
+	return nil
+}
+
+//fireUnsourced calls the functions registered with OnSet for the flags set by
+//the source just parsed, which are still marked as coming from the default
+func (fs *FlagSet) fireUnsourced() error {
+	for _, fitem := range fs.flags {
+		if !fitem.isSet || fitem.source != fromDefault {
+			continue
+		}
+		if err := fs.fire(fitem, fitem.values); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+//setSource records src as the source of the flags set since the previous call
+func (fs *FlagSet) setSource(src source) {
+	for _, fitem := range fs.flags {
+		if fitem.isSet && fitem.source == fromDefault {
+			fitem.source = src
+		}
+	}
+}
+
+//Reset clears the values collected by a previous parsing so that the FlagSet
+//can parse a new command line. Flags definitions are kept. The configuration
+//structure is not modified.
+func (fs *FlagSet) Reset() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, fitem := range fs.flags {
+		fitem.values = make([]string, 0)
+		fitem.isSet = false
+		fitem.source = fromDefault
+	}
+	fs.errs = make([]error, 0)
+	fs.args = make([]string, 0)
+	fs.command = ""
+	fs.commandArgs = make([]string, 0)
+	fs.parsed = false
+	for _, sub := range fs.commands {
+		sub.Reset()
+	}
+}
+
+//Parsed reports whether the command line has been parsed
+func (fs *FlagSet) Parsed() bool {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.parsed
+}
+
+//IsSet reports whether the flag registered as name has been set, on the
+//command line, with an environment variable or from a file, as opposed to
+//holding the value set in the configuration structure by the application
+func (fs *FlagSet) IsSet(name string) bool {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return false
+	}
+	return fitem.isSet
+}
+
+//Source returns where the value of the flag registered as name comes from:
+//"command line", "environment", "file", "Set" or "default". It returns an
+//error if name does not match any flag.
+func (fs *FlagSet) Source(name string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return fitem.source.String(), nil
+}
+
+//Terminal returns the first name of the terminal flag which stopped the last
+//parsing, or an empty string if parsing was not stopped
+func (fs *FlagSet) Terminal() string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.stoppedBy == nil {
+		return ""
+	}
+	return fs.stoppedBy.name()
+}
+
+//Args returns the positional arguments left after parsing the command line
+func (fs *FlagSet) Args() []string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return append([]string{}, fs.args...)
+}
+
+//AllowInterspersed enables or disables flags and positional arguments to be
+//mixed on the command line. When disabled (the default), the first argument
+//not starting with a dash and every argument after it are positional
+//arguments. When enabled, parsing goes on after a positional argument: with
+//"tool file1 --verbose file2", --verbose is parsed as a flag and file1 and
+//file2 are positional arguments. In both modes, every argument following "--"
+//is a positional argument.
+func (fs *FlagSet) AllowInterspersed(allow bool) {
+	fs.interspersed = allow
+}
+
+//CanonicalName returns the first name of the flag registered as name, or false
+//if name does not match any flag. name is resolved the same way it is on the
+//command line.
+func (fs *FlagSet) CanonicalName(name string) (string, bool) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return fitem.name(), true
+}
+
+func (fs *FlagSet) parseCommand(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := fs.ctx.Err(); err != nil {
+		return err
+	}
+
+	arg := args[0]
+	if arg == "--" {
+		fs.args = append(fs.args, args[1:]...)
+		return nil
+	}
+	if !strings.HasPrefix(arg, "-") || arg == "-" {
+		if _, ok := fs.commands[arg]; ok && len(fs.args) == 0 {
+			fs.command = arg
+			fs.commandArgs = args[1:]
+			return nil
+		}
+		if !fs.interspersed {
+			fs.args = append(fs.args, args...)
+			return nil
+		}
+		fs.args = append(fs.args, arg)
+		return fs.parseCommand(args[1:])
+	}
+
+	name, value, inline := arg, "", false
+	if i := strings.Index(arg, "="); i > 0 {
+		name, value, inline = arg[:i], arg[i+1:], true
+	}
+
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		if expanded, ok := fs.expandShort(arg); ok {
+			return fs.parseCommand(append(expanded, args[1:]...))
+		}
+		if err := fs.fail(err); err != nil {
+			return err
+		}
+		return fs.parseCommand(args[1:])
+	}
+
+	if len(fitem.deprecated) != 0 && !fs.dryRun {
+		fmt.Fprintf(fs.output, "flag %s is deprecated: %s\n", name, fitem.deprecated)
+	}
+	next := args[1:]
+
+	//boolean flag (valuation == none), given a value with "=" only, never with
+	//the following argument
+	if fitem.valuation == none {
+		if !inline {
+			value = "true"
+		}
+		if err := fs.parseValue(fitem, name, value); err != nil {
+			return err
+		}
+		return fs.parseNext(fitem, next)
+	}
+
+	//greedy flag, taking every following argument until "--"
+	if fitem.greedy {
+		values := make([]string, 0)
+		if inline {
+			values = append(values, value)
+		}
+		for len(next) != 0 && next[0] != "--" {
+			values = append(values, next[0])
+			next = next[1:]
+		}
+		if len(values) == 0 {
+			if err := fs.fail(fmt.Errorf("%w %s", ErrMissingValue, name)); err != nil {
+				return err
+			}
+		}
+		for _, v := range values {
+			if err := fs.parseValue(fitem, name, v); err != nil {
+				return err
+			}
+		}
+		return fs.parseNext(fitem, next)
+	}
+
+	if !inline {
+		if len(args) < 2 {
+			return fs.fail(fmt.Errorf("%w %s", ErrMissingValue, name))
+		}
+		if fs.isFlag(args[1]) && !fs.flagAsValue {
+			if err := fs.fail(fmt.Errorf("%w %s", ErrMissingValue, name)); err != nil {
+				return err
+			}
+			return fs.parseCommand(next)
+		}
+		value = args[1]
+		next = args[2:]
+	}
+
+	if err := fs.parseValue(fitem, name, value); err != nil {
+		return err
+	}
+	return fs.parseNext(fitem, next)
+}
+
+//parseNext parses args, the arguments following fitem on the command line,
+//unless fitem is a terminal flag: parsing then stops, args being positional
+//arguments, and ErrTerminal is returned
+func (fs *FlagSet) parseNext(fitem *flag, args []string) error {
+	if fitem.terminal {
+		fs.stoppedBy = fitem
+		fs.args = append(fs.args, args...)
+		return ErrTerminal
+	}
+	return fs.parseCommand(args)
+}
+
+//expandShort splits arg, an argument such as -vofile which is not a registered
+//flag, into the short flags it is made of: -v -o=file. Each character is a
+//boolean short flag, except the last flag which may expect a value, made of
+//the rest of arg or given by the following argument. It returns false if arg
+//is not made of short flags only.
+func (fs *FlagSet) expandShort(arg string) ([]string, bool) {
+	if len(arg) < 3 || strings.HasPrefix(arg, "--") {
+		return nil, false
+	}
+	expanded := make([]string, 0)
+	runes := []rune(arg[1:])
+	for i, r := range runes {
+		name := "-" + string(r)
+		fitem, ok := fs.fmap[name]
+		if !ok {
+			return nil, false
+		}
+		if fitem.valuation == none {
+			expanded = append(expanded, name)
+			continue
+		}
+		if i == len(runes)-1 {
+			return append(expanded, name), true
+		}
+		return append(expanded, name+"="+string(runes[i+1:])), true
+	}
+	return expanded, true
+}
+
+//zeroValue returns the string representation of the zero value of the field
+//of a monovaluated flag
+func (f *flag) zeroValue() string {
+	t := f.field.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	values := f.fieldValues(reflect.New(t).Elem())
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+//parseValue adds value, given on the command line for fitem as name, to the
+//values of the flag and calls the functions registered with OnSet. Values of
+//flags other than booleans are expanded and read from a file or the standard
+//input first, if enabled.
+func (fs *FlagSet) parseValue(fitem *flag, name string, value string) error {
+	if fitem.valuation != none && fs.expandEnv {
+		value = os.ExpandEnv(value)
+	}
+	if fitem.valuation == mono && fs.stdin && value == "-" {
+		if fs.stdinFlag != nil {
+			return fs.fail(fmt.Errorf("%w %q for %s: standard input already read for %s", ErrInvalidValue, value, name, fs.stdinFlag.name()))
+		}
+		fs.stdinFlag = fitem
+		//a validation leaves the input unread, the zero value standing for it
+		if fs.dryRun {
+			value = fitem.zeroValue()
+		} else {
+			v, err := readInput(fs.ctx, fs.input)
+			if err != nil {
+				return fs.fail(fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, value, name, err))
+			}
+			value = v
+		}
+	} else if fitem.valuation != none && fs.fileValues {
+		v, err := readValue(fs.ctx, value)
+		if err != nil {
+			return fs.fail(fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, value, name, err))
+		}
+		value = v
+	}
+	n := len(fitem.values)
+	if err := fs.addValue(fitem, name, value); err != nil {
+		return fs.fail(err)
+	}
+	return fs.fire(fitem, fitem.values[n:])
+}
+
+//addValue adds value, given for the flag fitem named name, to the values of
+//the flag according to its valuation
+func (fs *FlagSet) addValue(fitem *flag, name string, value string) error {
+	//boolean flag (valuation == none)
+	if fitem.valuation == none {
+		b, err := fs.parseBool(value)
+		if err != nil {
+			return &ConversionError{Flag: name, Value: value, Index: -1, Err: err}
+		}
+		fitem.values = append(fitem.values, strconv.FormatBool(b))
+		fitem.isSet = true
+		return nil
+	}
+
+	//mono flag (valuation == mono)
+	if fitem.valuation == mono && fitem.isSet {
+		return fmt.Errorf("%w %s", ErrDuplicateMono, name)
+	}
+
+	if fitem.valuation == mono {
+		fitem.values = append(fitem.values, value)
+		fitem.isSet = true
+		return nil
+	}
+
+	//multi flag (valuation == multi)
+	splitted, err := fitem.split(value)
+	if err != nil {
+		return fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, value, name, err)
+	}
+	if len(splitted) == 0 {
+		return fmt.Errorf("%w %s", ErrMissingValue, name)
+	}
+	fitem.values = append(fitem.values, splitted...)
+	fitem.isSet = true
+	return nil
+}
+
+//Set gives value to the flag registered as name as if it was given on the
+//command line: the value is added to the values of a multivaluated flag, a
+//monovaluated flag can only be set once and a boolean flag is given true or
+//false. The value is converted and validated, then stored into the
+//configuration structure. Values set are kept by a following parsing unless
+//Reset is called.
+func (fs *FlagSet) Set(name string, value string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return err
+	}
+	values, isSet := fitem.values, fitem.isSet
+	//values of a flag not set come from its default tag and are replaced
+	if !isSet {
+		fitem.values = make([]string, 0)
+	}
+	n := len(fitem.values)
+	if err := fs.addValue(fitem, name, value); err != nil {
+		fitem.values, fitem.isSet = values, isSet
+		return err
+	}
+	if err := fs.fire(fitem, fitem.values[n:]); err != nil {
+		fitem.values, fitem.isSet = values, isSet
+		return err
+	}
+	if err := fs.setField(fitem); err != nil {
+		fitem.values, fitem.isSet = values, isSet
+		return err
+	}
+	if fitem.source == fromDefault {
+		fitem.source = fromSet
+	}
+	return nil
+}
+
+func (fs *FlagSet) parseEnv() error {
+
+	for _, fitem := range fs.flags {
+		if fitem.isSet {
+			continue
+		}
+
+		env, values := fs.envValue(fitem)
+		if len(values) == 0 && fitem.envIndexed && len(env) != 0 {
+			if indexed := fs.indexedValues(env); len(indexed) != 0 {
+				fitem.values = append(fitem.values, indexed...)
+				fitem.isSet = true
+			}
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if fitem.valuation == none {
+			b, err := fs.parseBool(values)
+			if err != nil {
+				if err := fs.fail(&ConversionError{Flag: env, Value: values, Index: -1, Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			fitem.values = append(fitem.values, strconv.FormatBool(b))
+			fitem.isSet = true
+			continue
+		}
+
+		if fitem.valuation == mono {
+			fitem.values = append(fitem.values, values)
+			fitem.isSet = true
+			continue
+		}
+
+		splitted, err := fitem.splitEnv(values)
+		if err != nil {
+			if err := fs.fail(fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, values, env, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(splitted) == 0 {
+			continue
+		}
+		fitem.values = append(fitem.values, splitted...)
+		fitem.isSet = true
+	}
+
+	return nil
+}
+
+//split returns the values held by s for a multivaluated flag, split with the
+//separator of the flag
+func (f *flag) split(s string) ([]string, error) {
+	return f.splitWith(s, f.separator)
+}
+
+//splitEnv returns the values held by s, the value of the environment variable
+//of a multivaluated flag, split with the envsep tag or the separator of the
+//flag
+func (f *flag) splitEnv(s string) ([]string, error) {
+	if len(f.envSeparator) != 0 {
+		return f.splitWith(s, f.envSeparator)
+	}
+	return f.split(s)
+}
+
+//quotingSeparator reports whether sep can separate CSV fields
+func quotingSeparator(sep string) bool {
+	r, size := utf8.DecodeRuneInString(sep)
+	return size == len(sep) && r != utf8.RuneError && r != '"' && r != '\r' && r != '\n'
+}
+
+//splitWith returns the values held by s for a multivaluated flag, s being
+//split if sep is not empty, as a CSV record if the flag has the quoted tag.
+//Blank values, made of spaces only, are dropped unless the flag has the
+//keepempty tag, whether or not they are trimmed. Values are trimmed if the
+//flag has the trim tag.
+func (f *flag) splitWith(s string, sep string) ([]string, error) {
+	splitted := []string{s}
+	if len(sep) != 0 && f.quoted {
+		r := csv.NewReader(strings.NewReader(s))
+		r.Comma, _ = utf8.DecodeRuneInString(sep)
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = f.trim
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		splitted = make([]string, 0)
+		for _, record := range records {
+			splitted = append(splitted, record...)
+		}
+	} else if len(sep) != 0 {
+		splitted = strings.Split(s, sep)
+	}
+	values := make([]string, 0, len(splitted))
+	for _, v := range splitted {
+		if !f.keepEmpty && len(strings.TrimSpace(v)) == 0 {
+			continue
+		}
+		if f.trim {
+			v = strings.TrimSpace(v)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+//disabled reports whether the environment variable of the disableenv tag of
+//fitem is set to true
+func (fs *FlagSet) disabled(fitem *flag) (bool, error) {
+	if len(fitem.disableEnv) == 0 {
+		return false, nil
+	}
+	value := os.Getenv(fitem.disableEnv)
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := fs.parseBool(value)
+	if err != nil {
+		return false, &ConversionError{Flag: fitem.disableEnv, Value: value, Index: -1, Err: err}
+	}
+	return b, nil
+}
+
+func (fs *FlagSet) setConfig() error {
+	for _, fitem := range fs.flags {
+		//a flag disabled by its environment variable is set to the zero value
+		disabled, err := fs.disabled(fitem)
+		if err != nil {
+			if err := fs.fail(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if disabled {
+			fitem.field.Set(reflect.Zero(fitem.field.Type()))
+			fitem.values, fitem.isSet, fitem.source = make([]string, 0), false, fromDefault
+			continue
+		}
+
+		//values of a flag not set come from its default tag
+		if !fitem.isSet && len(fitem.values) == 0 {
+			continue
+		}
+
+		if err := fs.setField(fitem); err != nil {
+			if err := fs.fail(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//setField stores the values of the flag into the field v, allocating it if it
+//is a pointer
+//setField stores the values of fitem into its field, the values of a slice or
+//an array of booleans being read with the strings set with SetBoolStrings
+func (fs *FlagSet) setField(fitem *flag) error {
+	t := fitem.field.Type()
+	if fitem.valuation == multi && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Bool {
+		values := make([]string, 0, len(fitem.values))
+		for i, s := range fitem.values {
+			b, err := fs.parseBool(s)
+			if err != nil {
+				return &ConversionError{Flag: fitem.name(), Value: s, Index: i, Err: err}
+			}
+			values = append(values, strconv.FormatBool(b))
+		}
+		fitem.values = values
+	}
+	return fitem.setField(fitem.field)
+}
+
+func (f *flag) setField(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := f.set(ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	return f.set(v)
+}
+
+//defaultValues returns the values held by the default tag of the flag,
+//split like values given on the command line
+func (f *flag) defaultValues() ([]string, error) {
+	if f.valuation == none {
+		b, err := strconv.ParseBool(f.defaultTag)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strconv.FormatBool(b)}, nil
+	}
+	if f.valuation == mono {
+		return []string{f.defaultTag}, nil
+	}
+	return f.split(f.defaultTag)
+}
+
+//parseDefaults gives the values of their default tag to the flags set neither
+//on the command line, nor with an environment variable, nor from a file. Such
+//flags are not considered as set.
+func (fs *FlagSet) parseDefaults() error {
+	for _, fitem := range fs.flags {
+		if fitem.isSet || !fitem.hasDefault {
+			continue
+		}
+		values, err := fitem.defaultValues()
+		if err != nil {
+			if err := fs.fail(&ConversionError{Flag: fitem.name(), Value: fitem.defaultTag, Index: -1, Err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+		fitem.values = values
+	}
+	return nil
+}
+
+//set stores the values of the flag into v according to its valuation
+func (f *flag) set(v reflect.Value) error {
+	if f.valuation == none {
+		b, err := strconv.ParseBool(f.values[len(f.values)-1])
+		if err != nil {
+			return &ConversionError{Flag: f.name(), Value: f.values[len(f.values)-1], Index: -1, Err: err}
+		}
+		v.SetBool(b)
+		return nil
+	}
+
+	values, err := f.transform()
+	if err != nil {
+		return err
+	}
+
+	if f.valuation == mono {
+		if err := f.check(values[0], -1); err != nil {
+			return err
+		}
+		value := reflect.New(v.Type()).Elem()
+		if err := f.convert(value, values[0]); err != nil {
+			return &ConversionError{Flag: f.name(), Value: values[0], Index: -1, Err: err}
+		}
+		if err := f.checkRange(value, values[0], -1); err != nil {
+			return err
+		}
+		v.Set(value)
+		return nil
+	}
+
+	if f.unique {
+		values = uniqueValues(values)
+	}
+	if f.maxCount != 0 && len(values) > f.maxCount {
+		return fmt.Errorf("%w %s: %d values given, at most %d allowed", ErrTooManyValues, f.name(), len(values), f.maxCount)
+	}
+
+	if v.Kind() == reflect.Map {
+		return f.setMap(v, values)
+	}
+	if v.Kind() == reflect.Array {
+		if len(values) != v.Len() {
+			return fmt.Errorf("%w for %s: %d values given, %d expected", ErrInvalidValue, f.name(), len(values), v.Len())
+		}
+		newArray := reflect.New(v.Type()).Elem()
+		for i, vstr := range values {
+			elem, err := f.element(v.Type().Elem(), vstr, i)
+			if err != nil {
+				return err
+			}
+			newArray.Index(i).Set(elem)
+		}
+		v.Set(newArray)
+		return nil
+	}
+
+	newSlice := reflect.MakeSlice(v.Type(), 0, len(values))
+	if f.appendTo {
+		newSlice = reflect.AppendSlice(newSlice, f.base)
+	}
+	for i, vstr := range values {
+		elem, err := f.element(v.Type().Elem(), vstr, i)
+		if err != nil {
+			return err
+		}
+		newSlice = reflect.Append(newSlice, elem)
+	}
+	v.Set(newSlice)
+	return nil
+}
+
+//element returns vstr, the element at index i of a multivaluated flag,
+//validated and converted to t
+func (f *flag) element(t reflect.Type, vstr string, i int) (reflect.Value, error) {
+	if err := f.check(vstr, i); err != nil {
+		return reflect.Value{}, err
+	}
+	elem := reflect.New(t).Elem()
+	if err := f.convert(elem, vstr); err != nil {
+		return reflect.Value{}, &ConversionError{Flag: f.name(), Value: vstr, Index: i, Err: err}
+	}
+	if err := f.checkRange(elem, vstr, i); err != nil {
+		return reflect.Value{}, err
+	}
+	return elem, nil
+}
+
+//transform returns the values of the flag passed through the functions
+//registered with Transform
+func (f *flag) transform() ([]string, error) {
+	if len(f.transforms) == 0 {
+		return f.values, nil
+	}
+	values := make([]string, 0, len(f.values))
+	for _, s := range f.values {
+		for _, fn := range f.transforms {
+			t, err := fn(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, s, f.name(), err)
+			}
+			s = t
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+//setMap stores values, formatted as key=value, in the map v. If a key is
+//given several times, the last value wins.
+func (f *flag) setMap(v reflect.Value, values []string) error {
+	newMap := reflect.MakeMapWithSize(v.Type(), len(values))
+	for _, kv := range values {
+		k, e, ok := strings.Cut(kv, "=")
+		if !ok {
+			return &ConversionError{Flag: f.name(), Value: kv, Index: -1, Err: fmt.Errorf("key=value expected")}
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := setValue(key, k); err != nil {
+			return &ConversionError{Flag: f.name(), Value: kv, Index: -1, Err: err}
+		}
+		if err := f.check(e, -1); err != nil {
+			return err
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := f.convert(elem, e); err != nil {
+			return &ConversionError{Flag: f.name(), Value: kv, Index: -1, Err: err}
+		}
+		if err := f.checkRange(elem, e, -1); err != nil {
+			return err
+		}
+		newMap.SetMapIndex(key, elem)
+	}
+	v.Set(newMap)
+	return nil
+}
+
+//check validates the raw value s against the constraints set on the flag,
+//index being the position of s in a multivaluated flag or -1
+func (f *flag) check(s string, index int) error {
+	if len(f.choices) != 0 {
+		found := false
+		for _, c := range f.choices {
+			if s == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w %s for %s (allowed: %s)", ErrInvalidValue, quoteValue(s, index), f.name(), strings.Join(f.choices, ", "))
+		}
+	}
+	if f.match != nil && !f.match.MatchString(s) {
+		return fmt.Errorf("%w %s for %s: does not match %s", ErrInvalidValue, quoteValue(s, index), f.name(), f.match)
+	}
+	if f.maxLen != 0 && utf8.RuneCountInString(s) > f.maxLen {
+		return fmt.Errorf("%w %s for %s: longer than %d characters", ErrInvalidValue, quoteValue(s, index), f.name(), f.maxLen)
+	}
+	return nil
+}
+
+//checkRange validates v, converted from s, against the min and max tags of
+//the flag
+func (f *flag) checkRange(v reflect.Value, s string, index int) error {
+	if f.min.IsValid() && compare(v, f.min) < 0 {
+		return fmt.Errorf("%w %s for %s: below minimum %v", ErrInvalidValue, quoteValue(s, index), f.name(), f.min)
+	}
+	if f.max.IsValid() && compare(v, f.max) > 0 {
+		return fmt.Errorf("%w %s for %s: above maximum %v", ErrInvalidValue, quoteValue(s, index), f.name(), f.max)
+	}
+	return nil
+}
+
+//quoteValue quotes s, followed by its position when it is an element of a
+//multivaluated flag (index >= 0)
+func quoteValue(s string, index int) string {
+	if index < 0 {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%q (element %d)", s, index)
+}
+
+//compare returns -1, 0 or 1 if a is respectively lower, equal or greater than
+//b, a and b being numbers of the same kind
+func compare(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.Int() < b.Int() {
+			return -1
+		}
+		if a.Int() > b.Int() {
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if a.Uint() < b.Uint() {
+			return -1
+		}
+		if a.Uint() > b.Uint() {
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		if a.Float() < b.Float() {
+			return -1
+		}
+		if a.Float() > b.Float() {
+			return 1
+		}
+	}
+	return 0
+}
+
+//convert stores s in v, the field of the flag or one of its elements. Slices
+//of bytes are decoded according to the encoding tag and runes are taken from
+//single character strings. Fields with the json tag are unmarshaled from s,
+//fields with the enum tag are given the value named s and interface fields
+//are given the value built by the factory named s.
+//Other values are set with setValue.
+func (f *flag) convert(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Interface {
+		return f.build(v, s)
+	}
+	if f.enum != nil {
+		for _, e := range f.enum {
+			if e.name == s {
+				return setValue(v, e.value)
+			}
+		}
+		names := make([]string, 0, len(f.enum))
+		for _, e := range f.enum {
+			names = append(names, e.name)
+		}
+		return fmt.Errorf("expected one of %s", strings.Join(names, ", "))
+	}
+	if f.isJSON {
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	if f.unit == "bytes" {
+		n, err := byteSize(s)
+		if err != nil {
+			return err
+		}
+		return setValue(v, n)
+	}
+	if f.isRune && v.Kind() == reflect.Int32 {
+		if utf8.RuneCountInString(s) != 1 {
+			return fmt.Errorf("a single character is expected")
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		v.SetInt(int64(r))
+		return nil
+	}
+	if v.Kind() != reflect.Slice {
+		return setValue(v, s)
+	}
+	b := []byte(s)
+	var err error
+	switch f.encoding {
+	case "base64":
+		b, err = base64.StdEncoding.DecodeString(s)
+	case "hex":
+		b, err = hex.DecodeString(s)
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(b).Convert(v.Type()))
+	return nil
+}
+
+//scalar reports whether values of type t can be set with setValue
+func scalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+//byteSizes holds the multipliers of the units accepted by byteSize
+var byteSizes = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"pb":  1000 * 1000 * 1000 * 1000 * 1000,
+	"eb":  1000 * 1000 * 1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+	"eib": 1 << 60,
+}
+
+//byteSize returns the number of bytes, as an integer literal, of a size such
+//as 10MB or 1.5GiB. Units are matched regardless of case. A size without unit
+//may be any integer literal, such as 0x10; a size with a unit is a decimal
+//number.
+func byteSize(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if n, ok := new(big.Int).SetString(decimalLiteral(s), 0); ok {
+		return n.String(), nil
+	}
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+' && r != '_'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	multiplier, ok := byteSizes[unit]
+	if !ok {
+		return "", fmt.Errorf("unknown size unit %q", s[i:])
+	}
+	size, ok := new(big.Rat).SetString(strings.ReplaceAll(number, "_", ""))
+	if !ok || len(number) == 0 {
+		return "", fmt.Errorf("invalid size")
+	}
+	size.Mul(size, new(big.Rat).SetInt64(multiplier))
+	if !size.IsInt() {
+		return "", fmt.Errorf("size is not a whole number of bytes")
+	}
+	return size.Num().String(), nil
+}
+
+//setValue converts s according to the kind of v and stores the result in v
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(decimalLiteral(s), 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(decimalLiteral(s), 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetComplex(c)
+	default:
+		return fmt.Errorf("can not guess type: %s", v.Type().String())
+	}
+	return nil
+}
+
+//decimalLiteral returns s, an integer literal, without the leading zeros of a
+//decimal number, which would otherwise be read as an octal number: 010 is 10.
+//A literal with misplaced underscores is returned as is, to be rejected.
+func decimalLiteral(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	if len(s) < 2 || s[0] != '0' || strings.Trim(s, "0123456789_") != "" {
+		return sign + s
+	}
+	if strings.Contains(s, "__") || strings.HasSuffix(s, "_") {
+		return sign + s
+	}
+	s = strings.TrimLeft(s, "0_")
+	if len(s) == 0 {
+		s = "0"
+	}
+	return sign + s
+}
+
+//boolTag returns the boolean value of the tag key for the struct field, false
+//if the tag is not set
+func boolTag(ft reflect.StructField, key string) (bool, error) {
+	tag, ok := ft.Tag.Lookup(key)
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(tag))
+	if err != nil {
+		return false, fmt.Errorf("tag \"%s\" must be a boolean (%s)", key, ft.Name)
+	}
+	return b, nil
+}
+
+//elemKind returns the kind of t, or the kind of its elements for pointers,
+//slices and maps
+func elemKind(t reflect.Type) reflect.Kind {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return t.Elem().Kind()
+	}
+	return t.Kind()
+}
+
+//build stores in v, an interface value, the value returned by the factory
+//registered as s with RegisterFactory
+func (f *flag) build(v reflect.Value, s string) error {
+	if f.factories == nil {
+		return fmt.Errorf("no factory registered for %s", f.name())
+	}
+	factory, ok := f.factories[s]
+	if !ok {
+		names := make([]string, 0, len(f.factories))
+		for name := range f.factories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("expected one of %s", strings.Join(names, ", "))
+	}
+	built := reflect.ValueOf(factory())
+	if !built.IsValid() || !built.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("factory %s does not return a %s", s, v.Type().String())
+	}
+	v.Set(built)
+	return nil
+}
+
+//enumValue is a named value of the enum tag
+type enumValue struct {
+	name  string
+	value string
+}
+
+//parseEnum returns the named values held by tag, formatted as name=value,
+//for a field of type t
+func parseEnum(tag string, t reflect.Type) ([]enumValue, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, fmt.Errorf("is only supported on integers")
+	}
+	enum := make([]enumValue, 0)
+	for _, pair := range strings.Split(tag, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("must be formatted as name=value,...")
+		}
+		//values are decimal, in the range of the type of the field
+		v := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u, err := strconv.ParseUint(value, 10, t.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("holds an invalid value for %s", name)
+			}
+			v.SetUint(u)
+		default:
+			i, err := strconv.ParseInt(value, 10, t.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("holds an invalid value for %s", name)
+			}
+			v.SetInt(i)
+		}
+		value = formatInteger(v)
+		for _, e := range enum {
+			if e.name == name {
+				return nil, fmt.Errorf("holds %s several times", name)
+			}
+		}
+		enum = append(enum, enumValue{name: name, value: value})
+	}
+	return enum, nil
+}
+
+//checkName returns an error if name can not be matched on the command line:
+//a name starts with a dash, is not made of dashes only and holds neither
+//spaces nor equal signs
+func checkName(name string) error {
+	if !strings.HasPrefix(name, "-") || len(strings.Trim(name, "-")) == 0 {
+		return fmt.Errorf("invalid flag name %q: a name starts with a dash and is not made of dashes only", name)
+	}
+	if strings.ContainsAny(name, " \t\n\r=") {
+		return fmt.Errorf("invalid flag name %q: a name holds neither spaces nor equal signs", name)
+	}
+	return nil
+}
+
+//boundTag returns the value of the tag key for the struct field converted to
+//the type of the field (or of its elements for slices and pointers), an
+//invalid value if the tag is not set
+func boundTag(ft reflect.StructField, key string) (reflect.Value, error) {
+	tag, ok := ft.Tag.Lookup(key)
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	t := ft.Type
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return reflect.Value{}, fmt.Errorf("tag \"%s\" is only supported on numbers (%s)", key, ft.Name)
+	}
+	v := reflect.New(t).Elem()
+	tag = strings.TrimSpace(tag)
+	if strings.TrimSpace(ft.Tag.Get("unit")) == "bytes" {
+		if n, err := byteSize(tag); err == nil {
+			tag = n
+		}
+	}
+	if err := setValue(v, tag); err != nil {
+		return reflect.Value{}, fmt.Errorf("tag \"%s\" is not a valid %s (%s)", key, t.Kind().String(), ft.Name)
+	}
+	return v, nil
+}
+
+//uniqueValues returns values without duplicates, keeping the first occurrence
+//of each value
+func uniqueValues(values []string) []string {
+	seen := make(map[string]bool)
+	u := make([]string, 0, len(values))
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		u = append(u, v)
+	}
+	return u
+}
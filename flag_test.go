@@ -0,0 +1,1585 @@
+package flag
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAllowStdin(t *testing.T) {
+	type config struct {
+		Token string `names:"--token"`
+		Body  string `names:"--body"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.AllowStdin(true)
+	fs.SetInput(bytes.NewBufferString("s3cr3t\n"))
+	if err := fs.ParseArgs([]string{"--token", "-"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Token != "s3cr3t" {
+		t.Errorf("token: expected %q, got %q", "s3cr3t", c.Token)
+	}
+
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.AllowStdin(true)
+	fs.SetInput(bytes.NewBufferString("s3cr3t"))
+	if err := fs.ParseArgs([]string{"--token", "-", "--body", "-"}); err == nil {
+		t.Errorf("expected an error when two flags read the standard input")
+	}
+
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.AllowStdin(true)
+	fs.SetInput(bytes.NewBufferString("s3cr3t\n"))
+	if err := fs.Validate([]string{"--token", "-"}); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	if err := fs.ParseArgs([]string{"--token", "-"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Token != "s3cr3t" {
+		t.Errorf("token after validation: expected %q, got %q", "s3cr3t", c.Token)
+	}
+}
+
+func TestValidateHelpWritesNothing(t *testing.T) {
+	type config struct {
+		Help bool `names:"-h,--help" help:"true"`
+	}
+
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&config{})
+	fs.SetOutput(out)
+	if err := fs.Validate([]string{"--help"}); err != ErrHelp {
+		t.Errorf("expected ErrHelp, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got %q", out.String())
+	}
+}
+
+type logLevel int
+
+func (l logLevel) String() string {
+	return [...]string{"DEBUG", "INFO", "WARN"}[l]
+}
+
+func TestEnumArgsFromConfig(t *testing.T) {
+	type config struct {
+		Level logLevel `names:"--level" enum:"debug=0,info=1,warn=2"`
+	}
+
+	c := config{Level: 1}
+	fs := NewFlagSet(&c)
+	args := fs.ArgsFromConfig()
+	if len(args) != 1 || args[0] != "--level=info" {
+		t.Fatalf("expected [--level=info], got %v", args)
+	}
+	parsed := config{}
+	if err := NewFlagSet(&parsed).ParseArgs(args); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if parsed.Level != 1 {
+		t.Errorf("expected level 1, got %d", parsed.Level)
+	}
+}
+
+func TestEnumValueRange(t *testing.T) {
+	type unsigned struct {
+		Level uint8 `names:"--level" enum:"low=-1,high=1"`
+	}
+	if NewFlagSet(&unsigned{}) != nil {
+		t.Errorf("expected a negative enum value to be rejected on a uint8")
+	}
+	type small struct {
+		Level int8 `names:"--level" enum:"low=0,high=300"`
+	}
+	if NewFlagSet(&small{}) != nil {
+		t.Errorf("expected an enum value out of the int8 range to be rejected")
+	}
+}
+
+func TestTypedGetters(t *testing.T) {
+	type config struct {
+		Ports  []int    `names:"--p" sep:","`
+		Size   int64    `names:"--size" unit:"bytes"`
+		Level  logLevel `names:"--level" enum:"debug=0,info=1,warn=2"`
+		Flags  []string `names:"--flag" sep:","`
+		Count  int      `names:"--count"`
+		Unused int      `names:"--unused"`
+	}
+
+	c := config{Unused: 0x20}
+	fs := NewFlagSet(&c)
+	fs.SetBoolStrings([]string{"yes"}, []string{"no"})
+	args := []string{"--p", "0x10,1_000", "--size", "1KiB", "--level", "warn", "--flag", "yes,no", "--count", "010"}
+	if err := fs.ParseArgs(args); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ports, err := fs.GetIntSlice("--p")
+	if err != nil || len(ports) != 2 || ports[0] != 16 || ports[1] != 1000 {
+		t.Errorf("GetIntSlice: expected [16 1000], got %v (%v)", ports, err)
+	}
+	size, err := fs.GetInt("--size")
+	if err != nil || size != 1024 {
+		t.Errorf("GetInt with unit bytes: expected 1024, got %d (%v)", size, err)
+	}
+	level, err := fs.GetInt("--level")
+	if err != nil || level != 2 {
+		t.Errorf("GetInt with enum: expected 2, got %d (%v)", level, err)
+	}
+	flags, err := fs.GetBoolSlice("--flag")
+	if err != nil || len(flags) != 2 || !flags[0] || flags[1] {
+		t.Errorf("GetBoolSlice: expected [true false], got %v (%v)", flags, err)
+	}
+	count, err := fs.GetInt("--count")
+	if err != nil || count != c.Count {
+		t.Errorf("GetInt: expected %d as the field, got %d (%v)", c.Count, count, err)
+	}
+	unused, err := fs.GetInt("--unused")
+	if err != nil || unused != 0x20 {
+		t.Errorf("GetInt of a flag not set: expected 32, got %d (%v)", unused, err)
+	}
+	if _, err := fs.GetIntSlice("--flag"); err == nil {
+		t.Errorf("GetIntSlice: expected a conversion error")
+	}
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	type config struct {
+		N int  `names:"--n"`
+		U uint `names:"--u"`
+	}
+
+	tests := []struct {
+		arg      string
+		expected int
+	}{
+		{"10", 10},
+		{"010", 10},
+		{"08", 8},
+		{"-010", -10},
+		{"0", 0},
+		{"00", 0},
+		{"0x1F", 31},
+		{"0o17", 15},
+		{"0b101", 5},
+		{"1_000", 1000},
+		{"0x_1F", 31},
+	}
+	for _, test := range tests {
+		c := config{}
+		fs := NewFlagSet(&c)
+		if err := fs.ParseArgs([]string{"--n", test.arg, "--u", test.arg}); err != nil && test.expected >= 0 {
+			t.Errorf("%s: unexpected error: %s", test.arg, err)
+			continue
+		}
+		if c.N != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.arg, test.expected, c.N)
+		}
+		if test.expected >= 0 && c.U != uint(test.expected) {
+			t.Errorf("%s: expected unsigned %d, got %d", test.arg, test.expected, c.U)
+		}
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.ContinueOnError(true)
+	for _, arg := range []string{"1__0", "0__1", "00__1", "01_", "_01", "-0__1"} {
+		c = config{}
+		fs = NewFlagSet(&c)
+		fs.ContinueOnError(true)
+		if err := fs.ParseArgs([]string{"--n", arg}); err == nil {
+			t.Errorf("%s: expected an error for misplaced underscores, got %d", arg, c.N)
+		}
+	}
+	if got := decimalLiteral("0_1"); got != "1" {
+		t.Errorf("0_1: expected 1, got %s", got)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected string
+	}{
+		{"10", "10"},
+		{"010", "10"},
+		{"0x10", "16"},
+		{"1_000", "1000"},
+		{"10MB", "10000000"},
+		{"1.5GiB", "1610612736"},
+		{"2 kib", "2048"},
+	}
+	for _, test := range tests {
+		n, err := byteSize(test.size)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.size, err)
+			continue
+		}
+		if n != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.size, test.expected, n)
+		}
+	}
+	for _, size := range []string{"", "MB", "10XB", "0x10MB", "1.5B"} {
+		if _, err := byteSize(size); err == nil {
+			t.Errorf("%q: expected an error", size)
+		}
+	}
+}
+
+func TestValidateKeepsTerminal(t *testing.T) {
+	type config struct {
+		List bool   `names:"--list" terminal:"true"`
+		Name string `names:"--name"`
+	}
+
+	fs := NewFlagSet(&config{})
+	if err := fs.ParseArgs([]string{"--list"}); err != ErrTerminal {
+		t.Fatalf("expected ErrTerminal, got %v", err)
+	}
+	if err := fs.Validate([]string{"--name", "x"}); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	if fs.Terminal() != "--list" {
+		t.Errorf("expected terminal flag --list after validation, got %q", fs.Terminal())
+	}
+}
+
+func TestUsageDefaultFromConfig(t *testing.T) {
+	type config struct {
+		Path  string   `names:"--path" usage:"configuration path"`
+		Tags  []string `names:"--tags" sep:";"`
+		Level int      `names:"--level" default:"3"`
+		Name  string   `names:"--name"`
+	}
+
+	c := config{Path: "/etc/app.conf", Tags: []string{"a", "b"}}
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&c)
+	fs.SetOutput(out)
+	if err := fs.ParseArgs([]string{"--path", "/tmp/other.conf", "--level", "5"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fs.PrintDefaults()
+	for _, expected := range []string{"(default: /etc/app.conf)", "(default: a;b)", "(default: 3)"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in %q", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "/tmp/other.conf") {
+		t.Errorf("expected the default captured at creation, got %q", out.String())
+	}
+	if strings.Count(out.String(), "default:") != 3 {
+		t.Errorf("expected no default for a zero value, got %q", out.String())
+	}
+}
+
+func TestBashCompletionQuoting(t *testing.T) {
+	type config struct {
+		Mode string `names:"--mode" choices:"it's,$(reboot),a\"b"`
+	}
+
+	b := &strings.Builder{}
+	fs := NewFlagSet(&config{})
+	if err := fs.GenBashCompletion(b, "app"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `compgen -W 'it'\''s $(reboot) a"b' -- "$cur"`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("expected %s in %s", expected, b.String())
+	}
+	if !strings.Contains(b.String(), `compgen -W '--mode' -- "$cur"`) {
+		t.Errorf("expected single quoted flag names in %s", b.String())
+	}
+}
+
+func TestParseStringError(t *testing.T) {
+	fs := NewFlagSet(&struct {
+		Name string `names:"--name"`
+	}{})
+	err := fs.ParseString(`--name "unterminated`)
+	if err == nil || !strings.HasPrefix(err.Error(), "could not parse command line: ") {
+		t.Errorf("expected a command line parsing error, got %v", err)
+	}
+}
+
+func TestUniqueValues(t *testing.T) {
+	type unique struct {
+		Servers []string `names:"-s" unique:"true"`
+		Ports   []int    `names:"-p" sep:"," unique:"true"`
+	}
+	type repeated struct {
+		Servers []string `names:"-s"`
+	}
+
+	u := unique{}
+	if err := NewFlagSet(&u).ParseArgs([]string{"-s", "a", "-s", "a", "-s", "b", "-p", "80,443,80"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(u.Servers, []string{"a", "b"}) {
+		t.Errorf("unique: expected [a b], got %v", u.Servers)
+	}
+	if !reflect.DeepEqual(u.Ports, []int{80, 443}) {
+		t.Errorf("unique: expected [80 443], got %v", u.Ports)
+	}
+
+	r := repeated{}
+	if err := NewFlagSet(&r).ParseArgs([]string{"-s", "a", "-s", "a", "-s", "b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.Servers, []string{"a", "a", "b"}) {
+		t.Errorf("without unique: expected [a a b], got %v", r.Servers)
+	}
+}
+
+func TestBoolSlice(t *testing.T) {
+	type config struct {
+		Features []bool `names:"--feature" sep:","`
+	}
+
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--feature", "true", "--feature", "false", "--feature", "1,F"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Features, []bool{true, false, true, false}) {
+		t.Errorf("expected [true false true false], got %v", c.Features)
+	}
+
+	for _, value := range []string{"yes", "2", "true,maybe"} {
+		c = config{}
+		if err := NewFlagSet(&c).ParseArgs([]string{"--feature", value}); err == nil {
+			t.Errorf("%s: expected an error, got %v", value, c.Features)
+		}
+	}
+
+	c = config{}
+	fs := NewFlagSet(&c)
+	fs.SetBoolStrings([]string{"yes", "on"}, []string{"no", "off"})
+	if err := fs.ParseArgs([]string{"--feature", "yes", "--feature", "OFF,on"}); err != nil {
+		t.Fatalf("unexpected error with bool strings: %s", err)
+	}
+	if !reflect.DeepEqual(c.Features, []bool{true, false, true}) {
+		t.Errorf("bool strings: expected [true false true], got %v", c.Features)
+	}
+	if values, err := fs.GetBoolSlice("--feature"); err != nil || !reflect.DeepEqual(values, []bool{true, false, true}) {
+		t.Errorf("bool strings: expected GetBoolSlice to return [true false true], got %v (%v)", values, err)
+	}
+
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.SetBoolStrings([]string{"yes"}, []string{"no"})
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{"--feature", "yes,true"}); err == nil {
+		t.Errorf("bool strings: expected an error for true, got %v", c.Features)
+	}
+}
+
+func TestNormalizeDashes(t *testing.T) {
+	type config struct {
+		Server string `names:"--server"`
+		Port   int    `names:"-port"`
+	}
+
+	for _, args := range [][]string{
+		{"-server", "x", "-port", "80"},
+		{"--server", "x", "--port", "80"},
+		{"-server=x", "--port=80"},
+	} {
+		c := config{}
+		fs := NewFlagSet(&c)
+		fs.NormalizeDashes(true)
+		if err := fs.ParseArgs(args); err != nil {
+			t.Errorf("%v: unexpected error: %s", args, err)
+			continue
+		}
+		if c.Server != "x" || c.Port != 80 {
+			t.Errorf("%v: expected x and 80, got %q and %d", args, c.Server, c.Port)
+		}
+	}
+
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"-server", "x"}); err == nil {
+		t.Errorf("expected an error without NormalizeDashes, got %q", c.Server)
+	}
+}
+
+func TestNormalizeDashesVerbatim(t *testing.T) {
+	type config struct {
+		Short bool `names:"-v"`
+		Long  bool `names:"--v"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.NormalizeDashes(true)
+	if err := fs.ParseArgs([]string{"--v"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Short || !c.Long {
+		t.Errorf("expected the name declared verbatim to win, got -v %t and --v %t", c.Short, c.Long)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	type config struct {
+		Tags    []string `names:"--tags" env:"TEST_TRIM_TAGS" sep:","`
+		Trimmed []string `names:"--trimmed" env:"TEST_TRIM_TRIMMED" sep:"," trim:"true"`
+	}
+
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--tags", "a, b , c", "--trimmed", " a, b ,c "}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", " b ", " c"}) {
+		t.Errorf("without trim: expected %q, got %q", []string{"a", " b ", " c"}, c.Tags)
+	}
+	if !reflect.DeepEqual(c.Trimmed, []string{"a", "b", "c"}) {
+		t.Errorf("with trim: expected [a b c], got %q", c.Trimmed)
+	}
+
+	t.Setenv("TEST_TRIM_TAGS", "a, b")
+	t.Setenv("TEST_TRIM_TRIMMED", " a , b")
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", " b"}) {
+		t.Errorf("environment without trim: expected %q, got %q", []string{"a", " b"}, c.Tags)
+	}
+	if !reflect.DeepEqual(c.Trimmed, []string{"a", "b"}) {
+		t.Errorf("environment with trim: expected [a b], got %q", c.Trimmed)
+	}
+
+	fs := NewFlagSet(&struct {
+		Name string `names:"--name" trim:"true"`
+	}{})
+	if fs != nil {
+		t.Errorf("expected no FlagSet for the trim tag on a monovaluated flag")
+	}
+}
+
+func TestHiddenFlag(t *testing.T) {
+	type config struct {
+		Verbose bool   `names:"--verbose" usage:"verbose output"`
+		Debug   string `names:"--experimental-debug" hidden:"true" usage:"internal debugging"`
+	}
+
+	c := config{}
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&c)
+	fs.SetOutput(out)
+	if err := fs.ParseArgs([]string{"--experimental-debug", "trace"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Debug != "trace" {
+		t.Errorf("expected the hidden flag to be set to trace, got %q", c.Debug)
+	}
+	fs.Usage()
+	if !strings.Contains(out.String(), "--verbose") {
+		t.Errorf("expected --verbose in %q", out.String())
+	}
+	if strings.Contains(out.String(), "experimental-debug") || strings.Contains(out.String(), "internal debugging") {
+		t.Errorf("expected the hidden flag not to be printed, got %q", out.String())
+	}
+}
+
+func TestInterspersed(t *testing.T) {
+	type config struct {
+		Verbose bool `names:"--verbose"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.AllowInterspersed(true)
+	if err := fs.ParseArgs([]string{"file1", "--verbose", "file2", "--", "--verbose"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Verbose {
+		t.Errorf("interspersed: expected --verbose to be set")
+	}
+	if !reflect.DeepEqual(fs.Args(), []string{"file1", "file2", "--verbose"}) {
+		t.Errorf("interspersed: expected [file1 file2 --verbose], got %q", fs.Args())
+	}
+
+	c = config{}
+	fs = NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{"file1", "--verbose", "file2"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Verbose {
+		t.Errorf("strict: expected --verbose not to be set")
+	}
+	if !reflect.DeepEqual(fs.Args(), []string{"file1", "--verbose", "file2"}) {
+		t.Errorf("strict: expected [file1 --verbose file2], got %q", fs.Args())
+	}
+}
+
+func TestEnvOnlyFlag(t *testing.T) {
+	type config struct {
+		Token string `env:"TEST_ENV_ONLY_TOKEN"`
+		Name  string `names:"--name"`
+	}
+
+	t.Setenv("TEST_ENV_ONLY_TOKEN", "s3cr3t")
+	c := config{}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{"--name", "app"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Token != "s3cr3t" {
+		t.Errorf("expected the token from the environment, got %q", c.Token)
+	}
+	if len(fs.fmap) != 1 {
+		t.Errorf("expected only --name to be registered as a name, got %d names", len(fs.fmap))
+	}
+
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{"--token", "x"}); err == nil {
+		t.Errorf("expected an error when the environment only flag is given on the command line")
+	}
+	if c.Token != "s3cr3t" {
+		t.Errorf("expected the token from the environment, got %q", c.Token)
+	}
+
+	if fs := NewFlagSet(&struct {
+		Token string `names:""`
+	}{}); fs != nil {
+		t.Errorf("expected no FlagSet for a field with neither names nor env tag")
+	}
+}
+
+func TestAppendToDefaults(t *testing.T) {
+	type config struct {
+		Servers []string `names:"-s" env:"TEST_APPEND_SERVERS" sep:"," append:"true"`
+		Ports   []int    `names:"-p"`
+	}
+
+	c := config{Servers: []string{"a", "b"}, Ports: []int{80}}
+	if err := NewFlagSet(&c).ParseArgs([]string{"-s", "c", "-p", "443"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a", "b", "c"}) {
+		t.Errorf("append: expected [a b c], got %v", c.Servers)
+	}
+	if !reflect.DeepEqual(c.Ports, []int{443}) {
+		t.Errorf("without append: expected [443], got %v", c.Ports)
+	}
+
+	t.Setenv("TEST_APPEND_SERVERS", "d,e")
+	c = config{Servers: []string{"a", "b"}}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a", "b", "d", "e"}) {
+		t.Errorf("append from the environment: expected [a b d e], got %v", c.Servers)
+	}
+	fs.Reset()
+	if err := fs.ParseArgs([]string{"-s", "c"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a", "b", "c"}) {
+		t.Errorf("after Reset: expected [a b c], got %v", c.Servers)
+	}
+}
+
+// TestConcurrentReads is meant to be run with -race
+func TestConcurrentReads(t *testing.T) {
+	type config struct {
+		Name    string   `names:"--name"`
+		Servers []string `names:"-s" sep:","`
+	}
+
+	fs := NewFlagSet(&config{})
+	if err := fs.ParseArgs([]string{"--name", "app", "-s", "a,b", "file"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !fs.IsSet("--name") {
+					t.Errorf("expected --name to be set")
+				}
+				if f, ok := fs.Lookup("-s"); !ok || !f.IsSet {
+					t.Errorf("expected -s to be found and set")
+				}
+				if name, err := fs.GetString("--name"); err != nil || name != "app" {
+					t.Errorf("expected app, got %q (%v)", name, err)
+				}
+				if servers, err := fs.GetStringSlice("-s"); err != nil || len(servers) != 2 {
+					t.Errorf("expected 2 servers, got %v (%v)", servers, err)
+				}
+				if len(fs.Args()) != 1 {
+					t.Errorf("expected 1 positional argument, got %v", fs.Args())
+				}
+				fs.Visit(func(name string, values []string) {})
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := &config{}
+		clone := fs.Clone(c)
+		for j := 0; j < 100; j++ {
+			clone.Reset()
+			if err := clone.ParseArgs([]string{"--name", "other"}); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestEnvSeparator(t *testing.T) {
+	type config struct {
+		Paths []string `names:"--path" env:"TEST_ENVSEP_PATHS" envsep:":"`
+		Tags  []string `names:"--tag" env:"TEST_ENVSEP_TAGS" sep:","`
+	}
+
+	t.Setenv("TEST_ENVSEP_PATHS", "/usr/bin:/bin:/usr/local/bin")
+	t.Setenv("TEST_ENVSEP_TAGS", "a,b")
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Paths, []string{"/usr/bin", "/bin", "/usr/local/bin"}) {
+		t.Errorf("environment: expected 3 paths, got %q", c.Paths)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", "b"}) {
+		t.Errorf("environment without envsep: expected [a b], got %q", c.Tags)
+	}
+
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--path", "/opt/a:b", "--path", "/opt/c"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Paths, []string{"/opt/a:b", "/opt/c"}) {
+		t.Errorf("command line: expected [/opt/a:b /opt/c], got %q", c.Paths)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	type config struct {
+		Path  string `names:"--path"`
+		Other string `names:"--other"`
+		Name  string `names:"--name"`
+		Env   string `names:"--env" env:"TEST_EXPAND_VALUE"`
+	}
+
+	t.Setenv("TEST_EXPAND_HOME", "/home/user")
+	t.Setenv("TEST_EXPAND_UNSET", "")
+	os.Unsetenv("TEST_EXPAND_UNSET")
+	t.Setenv("TEST_EXPAND_VALUE", "$TEST_EXPAND_HOME")
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.ExpandEnv(true)
+	if err := fs.ParseArgs([]string{"--path", "${TEST_EXPAND_HOME}/data", "--other", "a${TEST_EXPAND_UNSET}b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Path != "/home/user/data" {
+		t.Errorf("set variable: expected /home/user/data, got %q", c.Path)
+	}
+	if c.Other != "ab" {
+		t.Errorf("unset variable: expected ab, got %q", c.Other)
+	}
+	if c.Env != "$TEST_EXPAND_HOME" {
+		t.Errorf("environment: expected $TEST_EXPAND_HOME not to be expanded, got %q", c.Env)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"name": "$TEST_EXPAND_HOME/app"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.ExpandEnv(true)
+	fs.SetArgsSource(func() []string { return []string{} })
+	if err := fs.ParseWithFile(path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Name != "/home/user/app" {
+		t.Errorf("file: expected /home/user/app, got %q", c.Name)
+	}
+
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--path", "${TEST_EXPAND_HOME}/data"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Path != "${TEST_EXPAND_HOME}/data" {
+		t.Errorf("disabled: expected the value as is, got %q", c.Path)
+	}
+}
+
+func TestEnvUpper(t *testing.T) {
+	type config struct {
+		Servers []string `names:"-s" env:"test_upper_servers" sep:","`
+	}
+
+	t.Setenv("TEST_UPPER_SERVERS", "a,b")
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.EnvUpper(true)
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a", "b"}) {
+		t.Errorf("expected [a b], got %q", c.Servers)
+	}
+
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(c.Servers) != 0 {
+		t.Errorf("without EnvUpper: expected no servers, got %q", c.Servers)
+	}
+
+	if fs := NewFlagSet(&struct {
+		Servers []string `names:"-s" env:"MY SERVERS"`
+	}{}); fs != nil {
+		t.Errorf("expected no FlagSet for an env tag holding a space")
+	}
+}
+
+func TestClearDefaultTrueBool(t *testing.T) {
+	type config struct {
+		Color bool `names:"--color"`
+	}
+
+	c := config{Color: true}
+	fs := NewFlagSet(&c)
+	cache := true
+	if err := fs.BoolVar(&cache, []string{"--cache"}, true, "use the cache"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fs.ParseArgs([]string{"--color=false", "--cache=false"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Color || cache {
+		t.Errorf("expected both flags cleared, got --color %t and --cache %t", c.Color, cache)
+	}
+	if b, err := fs.GetBool("--cache"); err != nil || b {
+		t.Errorf("expected GetBool to return false, got %t (%v)", b, err)
+	}
+
+	cache = true
+	fs = NewFlagSet(&config{})
+	if err := fs.BoolVar(&cache, []string{"--cache"}, true, "use the cache"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cache {
+		t.Errorf("expected the default true to be kept")
+	}
+}
+
+func TestKeepEmpty(t *testing.T) {
+	type config struct {
+		Fields []string `names:"--field" env:"TEST_KEEPEMPTY_FIELDS" sep:"," keepempty:"true"`
+		Tags   []string `names:"--tag" env:"TEST_KEEPEMPTY_TAGS" sep:","`
+	}
+
+	tests := []struct {
+		value   string
+		kept    []string
+		dropped []string
+	}{
+		{",a,b", []string{"", "a", "b"}, []string{"a", "b"}},
+		{"a,b,", []string{"a", "b", ""}, []string{"a", "b"}},
+		{"a,,b", []string{"a", "", "b"}, []string{"a", "b"}},
+		{",a,,b,", []string{"", "a", "", "b", ""}, []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		c := config{}
+		if err := NewFlagSet(&c).ParseArgs([]string{"--field", test.value, "--tag", test.value}); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.Fields, test.kept) {
+			t.Errorf("command line %q: expected %q, got %q", test.value, test.kept, c.Fields)
+		}
+		if !reflect.DeepEqual(c.Tags, test.dropped) {
+			t.Errorf("command line %q without keepempty: expected %q, got %q", test.value, test.dropped, c.Tags)
+		}
+
+		t.Setenv("TEST_KEEPEMPTY_FIELDS", test.value)
+		t.Setenv("TEST_KEEPEMPTY_TAGS", test.value)
+		c = config{}
+		if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.Fields, test.kept) {
+			t.Errorf("environment %q: expected %q, got %q", test.value, test.kept, c.Fields)
+		}
+		if !reflect.DeepEqual(c.Tags, test.dropped) {
+			t.Errorf("environment %q without keepempty: expected %q, got %q", test.value, test.dropped, c.Tags)
+		}
+	}
+}
+
+func TestEmptyMultiValue(t *testing.T) {
+	type config struct {
+		Tags   []string `names:"--tags"`
+		Split  []string `names:"--split" sep:","`
+		Fields []string `names:"--fields" sep:"," keepempty:"true"`
+	}
+
+	for _, args := range [][]string{
+		{"--tags", ""},
+		{"--split", ""},
+		{"--split", ",,"},
+		{"--split", " , "},
+	} {
+		c := config{}
+		fs := NewFlagSet(&c)
+		fs.ContinueOnError(true)
+		if err := fs.ParseArgs(args); !errors.Is(err, ErrMissingValue) {
+			t.Errorf("%q: expected ErrMissingValue, got %v", args, err)
+		}
+	}
+
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--fields", ",,"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Fields, []string{"", "", ""}) {
+		t.Errorf("keepempty: expected 3 empty values, got %q", c.Fields)
+	}
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--fields", ""}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Fields, []string{""}) {
+		t.Errorf("keepempty: expected an empty value, got %q", c.Fields)
+	}
+}
+
+func TestBoolInlineValueOnly(t *testing.T) {
+	type config struct {
+		Verbose bool `names:"--verbose"`
+	}
+
+	tests := []struct {
+		args       []string
+		verbose    bool
+		positional []string
+	}{
+		{[]string{"--verbose"}, true, []string{}},
+		{[]string{"--verbose", "true"}, true, []string{"true"}},
+		{[]string{"--verbose", "false"}, true, []string{"false"}},
+		{[]string{"--verbose=false"}, false, []string{}},
+		{[]string{"--verbose=true", "file"}, true, []string{"file"}},
+	}
+	for _, test := range tests {
+		c := config{}
+		fs := NewFlagSet(&c)
+		if err := fs.ParseArgs(test.args); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.args, err)
+			continue
+		}
+		if c.Verbose != test.verbose {
+			t.Errorf("%q: expected %t, got %t", test.args, test.verbose, c.Verbose)
+		}
+		if !reflect.DeepEqual(fs.Args(), test.positional) {
+			t.Errorf("%q: expected positional arguments %q, got %q", test.args, test.positional, fs.Args())
+		}
+	}
+}
+
+func TestShortFlagAttachedValue(t *testing.T) {
+	type config struct {
+		Output  string `names:"-o,--output"`
+		Verbose bool   `names:"-v"`
+		Extract bool   `names:"-x"`
+	}
+
+	tests := []struct {
+		args     []string
+		expected config
+	}{
+		{[]string{"-ofile"}, config{Output: "file"}},
+		{[]string{"-o/tmp/out"}, config{Output: "/tmp/out"}},
+		{[]string{"-o", "file"}, config{Output: "file"}},
+		{[]string{"-vx"}, config{Verbose: true, Extract: true}},
+		{[]string{"-vxofile"}, config{Output: "file", Verbose: true, Extract: true}},
+		{[]string{"-vo", "file"}, config{Output: "file", Verbose: true}},
+	}
+	for _, test := range tests {
+		c := config{}
+		if err := NewFlagSet(&c).ParseArgs(test.args); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.args, err)
+			continue
+		}
+		if c != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.args, test.expected, c)
+		}
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{"-vy"}); err == nil {
+		t.Errorf("expected an error for an unknown flag in a cluster")
+	}
+}
+
+func TestDefaultSeparator(t *testing.T) {
+	type config struct {
+		Servers []string `names:"-s" env:"TEST_DEFAULT_SEP_SERVERS"`
+		Paths   []string `names:"-p" sep:":"`
+		Name    string   `names:"--name"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	fs.SetDefaultSeparator(",")
+	if err := fs.ParseArgs([]string{"-s", "a,b", "-p", "/bin:/usr/bin,x", "--name", "a,b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a", "b"}) {
+		t.Errorf("default separator: expected [a b], got %q", c.Servers)
+	}
+	if !reflect.DeepEqual(c.Paths, []string{"/bin", "/usr/bin,x"}) {
+		t.Errorf("sep tag: expected [/bin /usr/bin,x], got %q", c.Paths)
+	}
+	if c.Name != "a,b" {
+		t.Errorf("monovaluated: expected a,b, got %q", c.Name)
+	}
+
+	t.Setenv("TEST_DEFAULT_SEP_SERVERS", "c,d")
+	c = config{}
+	fs = NewFlagSet(&c)
+	fs.SetDefaultSeparator(",")
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"c", "d"}) {
+		t.Errorf("environment: expected [c d], got %q", c.Servers)
+	}
+
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"-s", "a,b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Servers, []string{"a,b"}) {
+		t.Errorf("no default separator: expected [a,b], got %q", c.Servers)
+	}
+}
+
+func TestGroupedUsage(t *testing.T) {
+	type config struct {
+		Verbose bool   `names:"--verbose" usage:"verbose output"`
+		Host    string `names:"--host" group:"Networking" usage:"host to contact"`
+		Cache   string `names:"--cache" group:"Storage" usage:"cache directory"`
+		Port    int    `names:"--port" group:"Networking" usage:"port to contact"`
+		Quiet   bool   `names:"--quiet" usage:"no output"`
+	}
+
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&config{})
+	fs.SetOutput(out)
+	fs.PrintDefaults()
+	expected := []string{"Options", "--verbose", "--quiet", "Networking", "--host", "--port", "Storage", "--cache"}
+	previous := -1
+	for _, s := range expected {
+		i := strings.Index(out.String(), s)
+		if i <= previous {
+			t.Fatalf("expected %q in this order in %q", expected, out.String())
+		}
+		previous = i
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	type config struct {
+		Tags  []string `names:"--tags" sep:"," quoted:"true"`
+		Plain []string `names:"--plain" sep:","`
+	}
+
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{`"a,b",c`, []string{"a,b", "c"}},
+		{`a,"b,c",d`, []string{"a", "b,c", "d"}},
+		{`a,b`, []string{"a", "b"}},
+		{`"say ""hi""",x`, []string{`say "hi"`, "x"}},
+	}
+	for _, test := range tests {
+		c := config{}
+		if err := NewFlagSet(&c).ParseArgs([]string{"--tags", test.value}); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.Tags, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.value, test.expected, c.Tags)
+		}
+	}
+
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--plain", `"a,b",c`}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Plain, []string{`"a`, `b"`, "c"}) {
+		t.Errorf("without quoted: expected the quotes kept, got %q", c.Plain)
+	}
+
+	c = config{}
+	fs := NewFlagSet(&c)
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{"--tags", `"a,b`}); err == nil {
+		t.Errorf("expected an error for an unterminated quote, got %q", c.Tags)
+	}
+}
+
+func TestNumericBooleans(t *testing.T) {
+	type config struct {
+		Enabled bool `names:"--enabled" env:"TEST_NUMERIC_ENABLED"`
+	}
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"1", true},
+		{"0", false},
+		{"true", true},
+		{"false", false},
+	}
+	for _, test := range tests {
+		c := config{Enabled: !test.expected}
+		if err := NewFlagSet(&c).ParseArgs([]string{"--enabled=" + test.value}); err != nil {
+			t.Errorf("--enabled=%s: unexpected error: %s", test.value, err)
+			continue
+		}
+		if c.Enabled != test.expected {
+			t.Errorf("--enabled=%s: expected %t, got %t", test.value, test.expected, c.Enabled)
+		}
+
+		t.Setenv("TEST_NUMERIC_ENABLED", test.value)
+		c = config{Enabled: !test.expected}
+		if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+			t.Errorf("TEST_NUMERIC_ENABLED=%s: unexpected error: %s", test.value, err)
+			continue
+		}
+		if c.Enabled != test.expected {
+			t.Errorf("TEST_NUMERIC_ENABLED=%s: expected %t, got %t", test.value, test.expected, c.Enabled)
+		}
+	}
+
+	os.Unsetenv("TEST_NUMERIC_ENABLED")
+	fs := NewFlagSet(&config{})
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{"--enabled=2"}); err == nil {
+		t.Errorf("--enabled=2: expected an error")
+	}
+	t.Setenv("TEST_NUMERIC_ENABLED", "2")
+	fs = NewFlagSet(&config{})
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{}); err == nil {
+		t.Errorf("TEST_NUMERIC_ENABLED=2: expected an error")
+	}
+}
+
+func TestTrimKeepEmptyCombinations(t *testing.T) {
+	type config struct {
+		Neither []string `names:"--neither" sep:","`
+		Trim    []string `names:"--trim" sep:"," trim:"true"`
+		Keep    []string `names:"--keep" sep:"," keepempty:"true"`
+		Both    []string `names:"--both" sep:"," trim:"true" keepempty:"true"`
+	}
+
+	value := "a, , b,"
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--neither", value, "--trim", value, "--keep", value, "--both", value}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(c.Neither, []string{"a", " b"}) {
+		t.Errorf("neither: expected %q, got %q", []string{"a", " b"}, c.Neither)
+	}
+	if !reflect.DeepEqual(c.Trim, []string{"a", "b"}) {
+		t.Errorf("trim: expected %q, got %q", []string{"a", "b"}, c.Trim)
+	}
+	if !reflect.DeepEqual(c.Keep, []string{"a", " ", " b", ""}) {
+		t.Errorf("keepempty: expected %q, got %q", []string{"a", " ", " b", ""}, c.Keep)
+	}
+	if !reflect.DeepEqual(c.Both, []string{"a", "", "b", ""}) {
+		t.Errorf("both: expected %q, got %q", []string{"a", "", "b", ""}, c.Both)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		cmdline  string
+		expected []string
+	}{
+		{``, []string{}},
+		{`   `, []string{}},
+		{`-v --name app`, []string{"-v", "--name", "app"}},
+		{" a\tb\nc  ", []string{"a", "b", "c"}},
+		{`'it''s'`, []string{"its"}},
+		{`'a "b" \c $d'`, []string{`a "b" \c $d`}},
+		{`"it's"`, []string{"it's"}},
+		{`"a \"b\" \\ \$HOME \c"`, []string{`a "b" \ $HOME \c`}},
+		{"\"a\\\nb\"", []string{"ab"}},
+		{`a\ b \'c\'`, []string{"a b", "'c'"}},
+		{"a\\\nb", []string{"ab"}},
+		{`--name="a b"`, []string{"--name=a b"}},
+		{`x'y'"z"`, []string{"xyz"}},
+		{`"" ''`, []string{"", ""}},
+		{`--path 'C:\tmp'`, []string{"--path", `C:\tmp`}},
+	}
+	for _, test := range tests {
+		args, err := splitCommandLine(test.cmdline)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.cmdline, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.cmdline, test.expected, args)
+		}
+	}
+
+	for _, cmdline := range []string{`'a`, `"a`, `a\`, `"a\"`, `'a'"b`} {
+		if args, err := splitCommandLine(cmdline); err == nil {
+			t.Errorf("%q: expected an error, got %q", cmdline, args)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+	type config struct {
+		Verbose bool   `names:"-v"`
+		Message string `names:"--message"`
+		Path    string `names:"--path"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseString(`-v --message "it's done" --path 'C:\tmp' file`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Verbose || c.Message != "it's done" || c.Path != `C:\tmp` {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+	if !reflect.DeepEqual(fs.Args(), []string{"file"}) {
+		t.Errorf("expected [file], got %q", fs.Args())
+	}
+}
+
+func TestEnvFallbackChain(t *testing.T) {
+	type config struct {
+		Host string `names:"--host" env:"TEST_CHAIN_NEW_HOST,TEST_CHAIN_OLD_HOST"`
+	}
+
+	t.Setenv("TEST_CHAIN_OLD_HOST", "old.example.com")
+	c := config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Host != "old.example.com" {
+		t.Errorf("second name: expected old.example.com, got %q", c.Host)
+	}
+
+	t.Setenv("TEST_CHAIN_NEW_HOST", "new.example.com")
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Host != "new.example.com" {
+		t.Errorf("first name: expected new.example.com, got %q", c.Host)
+	}
+
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"--host", "cli.example.com"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Host != "cli.example.com" {
+		t.Errorf("command line: expected cli.example.com, got %q", c.Host)
+	}
+}
+
+func TestDoubleDashValue(t *testing.T) {
+	type config struct {
+		Arg     string `names:"--arg"`
+		Verbose bool   `names:"-v"`
+	}
+
+	tests := []struct {
+		args       []string
+		expected   config
+		positional []string
+	}{
+		{[]string{"--", "-v"}, config{}, []string{"-v"}},
+		{[]string{"-v", "--", "--arg", "x"}, config{Verbose: true}, []string{"--arg", "x"}},
+		{[]string{"--arg", "--", "-v"}, config{Arg: "--", Verbose: true}, []string{}},
+		{[]string{"--arg", "--", "--", "-v"}, config{Arg: "--"}, []string{"-v"}},
+		{[]string{"--arg=--"}, config{Arg: "--"}, []string{}},
+	}
+	for _, test := range tests {
+		c := config{}
+		fs := NewFlagSet(&c)
+		if err := fs.ParseArgs(test.args); err != nil {
+			t.Errorf("%q: unexpected error: %s", test.args, err)
+			continue
+		}
+		if c != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.args, test.expected, c)
+		}
+		if !reflect.DeepEqual(fs.Args(), test.positional) {
+			t.Errorf("%q: expected positional arguments %q, got %q", test.args, test.positional, fs.Args())
+		}
+	}
+}
+
+func TestDisableEnv(t *testing.T) {
+	type config struct {
+		Feature bool     `names:"--feature" env:"TEST_DISABLE_FEATURE" disableenv:"TEST_DISABLE_KILL"`
+		Servers []string `names:"-s" sep:"," default:"a,b" disableenv:"TEST_DISABLE_KILL"`
+	}
+
+	t.Setenv("TEST_DISABLE_FEATURE", "true")
+	c := config{}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Feature || len(c.Servers) != 2 {
+		t.Errorf("not disabled: expected the feature and 2 servers, got %+v", c)
+	}
+
+	for _, value := range []string{"true", "1"} {
+		t.Setenv("TEST_DISABLE_KILL", value)
+		c = config{Feature: true, Servers: []string{"x"}}
+		fs = NewFlagSet(&c)
+		if err := fs.ParseArgs([]string{"--feature", "-s", "c,d"}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if c.Feature || c.Servers != nil {
+			t.Errorf("disabled with %s: expected zero values, got %+v", value, c)
+		}
+	}
+	if fs.IsSet("--feature") {
+		t.Errorf("disabled: expected --feature not to be set")
+	}
+	if source, err := fs.Source("--feature"); err != nil || source != fromDefault.String() {
+		t.Errorf("disabled: expected the default source, got %q (%v)", source, err)
+	}
+	if b, err := fs.GetBool("--feature"); err != nil || b {
+		t.Errorf("disabled: expected GetBool to return false, got %t (%v)", b, err)
+	}
+	if servers, err := fs.GetStringSlice("-s"); err != nil || len(servers) != 0 {
+		t.Errorf("disabled: expected no servers, got %q (%v)", servers, err)
+	}
+	fs.Visit(func(name string, values []string) {
+		t.Errorf("disabled: expected nothing to visit, got %s %q", name, values)
+	})
+
+	t.Setenv("TEST_DISABLE_KILL", "false")
+	c = config{}
+	if err := NewFlagSet(&c).ParseArgs([]string{"-s", "c"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Feature || !reflect.DeepEqual(c.Servers, []string{"c"}) {
+		t.Errorf("disabled with false: expected the feature and [c], got %+v", c)
+	}
+
+	t.Setenv("TEST_DISABLE_KILL", "maybe")
+	fs = NewFlagSet(&config{})
+	fs.ContinueOnError(true)
+	if err := fs.ParseArgs([]string{}); err == nil {
+		t.Errorf("expected an error for an invalid boolean in the disableenv variable")
+	}
+}
+
+func TestRepeatedNames(t *testing.T) {
+	type config struct {
+		Verbose bool `names:"-v,--verbose,-v"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	if fs == nil {
+		t.Fatalf("expected a FlagSet for a repeated name")
+	}
+	f, ok := fs.Lookup("-v")
+	if !ok {
+		t.Fatalf("expected -v to be registered")
+	}
+	if !reflect.DeepEqual(f.Names, []string{"-v", "--verbose"}) {
+		t.Errorf("expected [-v --verbose], got %q", f.Names)
+	}
+	if err := fs.ParseArgs([]string{"-v"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !c.Verbose {
+		t.Errorf("expected -v to be set")
+	}
+}
+
+func TestParseTwiceWithDefaults(t *testing.T) {
+	type config struct {
+		Port int    `names:"--port" default:"80"`
+		Host string `names:"--host" env:"TEST_TWICE_HOST" default:"localhost"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Port != 80 || c.Host != "localhost" {
+		t.Errorf("first parse: expected the defaults, got %+v", c)
+	}
+
+	t.Setenv("TEST_TWICE_HOST", "example.com")
+	if err := fs.ParseArgs([]string{"--port", "90"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Port != 90 {
+		t.Errorf("second parse: expected port 90, got %d", c.Port)
+	}
+	if c.Host != "example.com" {
+		t.Errorf("second parse: expected host example.com from the environment, got %q", c.Host)
+	}
+}
+
+func TestArgsFromConfigDashValues(t *testing.T) {
+	type config struct {
+		Verbose bool     `names:"-v"`
+		Name    string   `names:"--name"`
+		Offsets []int    `names:"--offset"`
+		Labels  []string `names:"--label"`
+	}
+
+	c := config{Name: "-v", Offsets: []int{-1, 2}, Labels: []string{"--name", "a=b"}}
+	args := NewFlagSet(&c).ArgsFromConfig()
+	parsed := config{}
+	if err := NewFlagSet(&parsed).ParseArgs(args); err != nil {
+		t.Fatalf("%q: unexpected error: %s", args, err)
+	}
+	if !reflect.DeepEqual(parsed, c) {
+		t.Errorf("%q: expected %+v, got %+v", args, c, parsed)
+	}
+}
+
+func TestArgsFromConfigGreedy(t *testing.T) {
+	type config struct {
+		Files []string `names:"--files" greedy:"true"`
+		Name  string   `names:"--name"`
+		Tags  []string `names:"--tag"`
+	}
+
+	c := config{Files: []string{"a", "-b", "c"}, Name: "x", Tags: []string{"t1", "t2"}}
+	args := NewFlagSet(&c).ArgsFromConfig()
+	expected := []string{"--name=x", "--tag=t1", "--tag=t2", "--files", "a", "-b", "c"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+	parsed := config{}
+	if err := NewFlagSet(&parsed).ParseArgs(args); err != nil {
+		t.Fatalf("%q: unexpected error: %s", args, err)
+	}
+	if !reflect.DeepEqual(parsed, c) {
+		t.Errorf("%q: expected %+v, got %+v", args, c, parsed)
+	}
+}
+
+func TestGettersReadConfiguration(t *testing.T) {
+	type config struct {
+		Name   string    `names:"--name"`
+		Tags   []string  `names:"--tag" unique:"true"`
+		Extra  []string  `names:"--extra" append:"true"`
+		Ratios []float64 `names:"--ratio" sep:","`
+	}
+
+	c := config{Extra: []string{"base"}}
+	fs := NewFlagSet(&c)
+	if err := fs.Transform("--name", func(s string) (string, error) { return strings.ToUpper(s), nil }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fs.ParseArgs([]string{"--name", "bob", "--tag", "x", "--tag", "x", "--extra", "n", "--ratio", "0.5,1_0"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if name, err := fs.GetString("--name"); err != nil || name != "BOB" {
+		t.Errorf("transform: expected BOB, got %q (%v)", name, err)
+	}
+	if tags, err := fs.GetStringSlice("--tag"); err != nil || !reflect.DeepEqual(tags, []string{"x"}) {
+		t.Errorf("unique: expected [x], got %q (%v)", tags, err)
+	}
+	if extra, err := fs.GetStringSlice("--extra"); err != nil || !reflect.DeepEqual(extra, []string{"base", "n"}) {
+		t.Errorf("append: expected [base n], got %q (%v)", extra, err)
+	}
+	if ratios, err := fs.GetFloat64Slice("--ratio"); err != nil || !reflect.DeepEqual(ratios, []float64{0.5, 10}) {
+		t.Errorf("expected [0.5 10], got %v (%v)", ratios, err)
+	}
+}
+
+func TestNewFlagSetE(t *testing.T) {
+	type config struct {
+		Name   string `names:"--name"`
+		hidden string
+	}
+
+	fs, err := NewFlagSetE(&config{})
+	if err == nil || fs != nil {
+		t.Fatalf("expected an error for an unexported field, got %v", fs)
+	}
+	if !strings.Contains(err.Error(), "hidden") {
+		t.Errorf("expected the error to name the field, got %q", err)
+	}
+	if _, err := NewFlagSetE(config{}); err == nil {
+		t.Errorf("expected an error for a configuration which is not a pointer")
+	}
+	if fs, err := NewFlagSetE(&struct {
+		Name string `names:"--name"`
+	}{}); err != nil || fs == nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	type config struct {
+		Name    string   `names:"--name"`
+		Servers []string `names:"-s"`
+		Port    int      `names:"--port" env:"TEST_RESET_PORT"`
+	}
+
+	t.Setenv("TEST_RESET_PORT", "8080")
+	c := config{}
+	fs := NewFlagSet(&c)
+	if err := fs.ParseArgs([]string{"--name", "first", "-s", "a", "-s", "b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if source, _ := fs.Source("--port"); source != fromEnv.String() {
+		t.Errorf("first parse: expected --port from the environment, got %q", source)
+	}
+
+	fs.Reset()
+	for _, name := range []string{"--name", "-s", "--port"} {
+		if fs.IsSet(name) {
+			t.Errorf("after Reset: expected %s not to be set", name)
+		}
+		if source, _ := fs.Source(name); source != fromDefault.String() {
+			t.Errorf("after Reset: expected the default source for %s, got %q", name, source)
+		}
+	}
+	if len(fs.Args()) != 0 {
+		t.Errorf("after Reset: expected no positional arguments, got %q", fs.Args())
+	}
+
+	os.Unsetenv("TEST_RESET_PORT")
+	c = config{}
+	if err := fs.ParseArgs([]string{"-s", "c", "file"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Name != "" || c.Port != 0 || !reflect.DeepEqual(c.Servers, []string{"c"}) {
+		t.Errorf("second parse: expected only -s c, got %+v", c)
+	}
+	if fs.IsSet("--name") || !fs.IsSet("-s") || fs.IsSet("--port") {
+		t.Errorf("second parse: expected only -s to be set")
+	}
+	if source, _ := fs.Source("-s"); source != fromCommandLine.String() {
+		t.Errorf("second parse: expected -s from the command line, got %q", source)
+	}
+	if !reflect.DeepEqual(fs.Args(), []string{"file"}) {
+		t.Errorf("second parse: expected [file], got %q", fs.Args())
+	}
+}
+
+func TestDump(t *testing.T) {
+	type config struct {
+		Delimiter rune     `names:"--delimiter" rune:"true"`
+		Key       []byte   `names:"--key" encoding:"hex"`
+		Level     logLevel `names:"--level" enum:"debug=0,info=1,warn=2"`
+		Filter    struct {
+			Name string `json:"name"`
+		} `names:"--filter" json:"true"`
+		Servers []string `names:"-s" sep:";"`
+		Port    *int     `names:"--port"`
+	}
+
+	c := config{}
+	fs := NewFlagSet(&c)
+	args := []string{"--delimiter", ",", "--key", "0aff", "--level", "warn", "--filter", `{"name":"web"}`, "-s", "a;b"}
+	if err := fs.ParseArgs(args); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	out := &bytes.Buffer{}
+	fs.Dump(out)
+	expected := "--delimiter: , (command line)\n" +
+		"--key: 0aff (command line)\n" +
+		"--level: warn (command line)\n" +
+		"--filter: {\"name\":\"web\"} (command line)\n" +
+		"-s: a;b (command line)\n" +
+		"--port:  (default)\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWarnUnsetEnvFallbacks(t *testing.T) {
+	type config struct {
+		Host string `names:"--host" env:"TEST_WARN_NEWH,TEST_WARN_OLDH"`
+		Port int    `names:"--port" env:"TEST_WARN_PORT"`
+	}
+
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&config{})
+	fs.SetOutput(out)
+	fs.WarnUnsetEnv(true)
+	if err := fs.ParseArgs([]string{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "warning: no value for --host, none of the environment variables TEST_WARN_NEWH, TEST_WARN_OLDH is set\n" +
+		"warning: no value for --port, environment variable TEST_WARN_PORT is not set\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestValidateWritesNoWarning(t *testing.T) {
+	type config struct {
+		Old  string `names:"--old" deprecated:"use --new instead"`
+		Host string `names:"--host" env:"TEST_VALIDATE_WARN_HOST"`
+	}
+
+	out := &bytes.Buffer{}
+	fs := NewFlagSet(&config{})
+	fs.SetOutput(out)
+	fs.WarnUnsetEnv(true)
+	if err := fs.Validate([]string{"--old", "x"}); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output from Validate, got %q", out.String())
+	}
+	if err := fs.ParseArgs([]string{"--old", "x"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(out.String(), "deprecated") || !strings.Contains(out.String(), "TEST_VALIDATE_WARN_HOST") {
+		t.Errorf("expected the warnings from ParseArgs, got %q", out.String())
+	}
+}
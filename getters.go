@@ -0,0 +1,164 @@
+package flag
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//GetValues converts with conv the values of the flag registered as name and
+//returns them. The values are the ones held by the configuration structure,
+//once transformed, deduplicated or appended like any parsed value, formatted
+//the way they are given on the command line. For example:
+// timeouts, err := GetValues(fs, "--timeout", time.ParseDuration)
+//
+func GetValues[T any](fs *FlagSet, name string, conv func(string) (T, error)) ([]T, error) {
+	return getValues(fs, name, func(fitem *flag, s string) (T, error) { return conv(s) })
+}
+
+//getValues converts with conv the values of the flag registered as name, conv
+//being given the flag
+func getValues[T any](fs *FlagSet, name string, conv func(fitem *flag, s string) (T, error)) ([]T, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	values := fitem.fieldValues(fitem.field)
+	converted := make([]T, 0, len(values))
+	for i, s := range values {
+		v, err := conv(fitem, s)
+		if err != nil {
+			if fitem.valuation != multi {
+				i = -1
+			}
+			return nil, &ConversionError{Flag: fitem.name(), Value: s, Index: i, Err: err}
+		}
+		converted = append(converted, v)
+	}
+	return converted, nil
+}
+
+//GetStringMap returns the values of the flag registered as name as a map, each
+//value being a key=value pair. If a key is given several times, the last value
+//wins. A value without "=" returns an error.
+func (fs *FlagSet) GetStringMap(name string) (map[string]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	values := fitem.fieldValues(fitem.field)
+	m := make(map[string]string, len(values))
+	for i, kv := range values {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			if fitem.valuation != multi {
+				i = -1
+			}
+			return nil, &ConversionError{Flag: fitem.name(), Value: kv, Index: i, Err: fmt.Errorf("key=value expected")}
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+//converted converts s, a value of fitem, to T the way values are converted
+//to be stored in the configuration structure: integer literals, sizes and
+//enum names are accepted, as well as the booleans set with SetBoolStrings
+func converted[T any](fs *FlagSet) func(fitem *flag, s string) (T, error) {
+	return func(fitem *flag, s string) (T, error) {
+		var value T
+		v := reflect.ValueOf(&value).Elem()
+		if v.Kind() != reflect.Bool {
+			err := fitem.convert(v, s)
+			return value, err
+		}
+		b, err := fs.parseBool(s)
+		if err != nil {
+			//values of boolean flags are held as true or false
+			if b, err = strconv.ParseBool(s); err != nil {
+				return value, err
+			}
+		}
+		v.SetBool(b)
+		return value, nil
+	}
+}
+
+//getValue converts with conv the value of the flag registered as name, the
+//last one if several values are held
+func getValue[T any](fs *FlagSet, name string, conv func(fitem *flag, s string) (T, error)) (T, error) {
+	values, err := getValues(fs, name, conv)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if len(values) == 0 {
+		var zero T
+		return zero, fmt.Errorf("%w %s", ErrMissingValue, name)
+	}
+	return values[len(values)-1], nil
+}
+
+//GetString returns the value of the flag registered as name, held by the
+//configuration structure
+func (fs *FlagSet) GetString(name string) (string, error) {
+	return getValue(fs, name, func(fitem *flag, s string) (string, error) { return s, nil })
+}
+
+//GetInt returns the value of the flag registered as name converted to an int
+func (fs *FlagSet) GetInt(name string) (int, error) {
+	return getValue(fs, name, converted[int](fs))
+}
+
+//GetFloat returns the value of the flag registered as name converted to a
+//float64
+func (fs *FlagSet) GetFloat(name string) (float64, error) {
+	return getValue(fs, name, converted[float64](fs))
+}
+
+//GetBool returns the value of the flag registered as name converted to a bool
+func (fs *FlagSet) GetBool(name string) (bool, error) {
+	return getValue(fs, name, converted[bool](fs))
+}
+
+//GetDuration returns the value of the flag registered as name converted to a
+//time.Duration
+func (fs *FlagSet) GetDuration(name string) (time.Duration, error) {
+	return getValue(fs, name, converted[time.Duration](fs))
+}
+
+//GetStringSlice returns the values of the flag registered as name, held by the
+//configuration structure
+func (fs *FlagSet) GetStringSlice(name string) ([]string, error) {
+	return GetValues(fs, name, func(s string) (string, error) { return s, nil })
+}
+
+//GetIntSlice returns the values of the flag registered as name converted to
+//ints
+func (fs *FlagSet) GetIntSlice(name string) ([]int, error) {
+	return getValues(fs, name, converted[int](fs))
+}
+
+//GetFloat64Slice returns the values of the flag registered as name converted
+//to float64s
+func (fs *FlagSet) GetFloat64Slice(name string) ([]float64, error) {
+	return getValues(fs, name, converted[float64](fs))
+}
+
+//GetBoolSlice returns the values of the flag registered as name converted to
+//bools
+func (fs *FlagSet) GetBoolSlice(name string) ([]bool, error) {
+	return getValues(fs, name, converted[bool](fs))
+}
+
+//GetDurationSlice returns the values of the flag registered as name converted
+//to time.Durations
+func (fs *FlagSet) GetDurationSlice(name string) ([]time.Duration, error) {
+	return getValues(fs, name, converted[time.Duration](fs))
+}
@@ -0,0 +1,108 @@
+package flag
+
+//Valuation tells how many values a flag accepts
+type Valuation int
+
+const (
+	//Boolean flags do not accept any value
+	Boolean Valuation = iota
+	//Monovaluated flags accept one and only one value
+	Monovaluated
+	//Multivaluated flags accept several values
+	Multivaluated
+)
+
+func (v Valuation) String() string {
+	switch v {
+	case Monovaluated:
+		return "monovaluated"
+	case Multivaluated:
+		return "multivaluated"
+	}
+	return "boolean"
+}
+
+//Flag describes a flag registered in a FlagSet. It is a copy: modifying it
+//has no effect on the FlagSet.
+type Flag struct {
+	//Names of the flag, the first one being the canonical name. Environment
+	//only flags have no names.
+	Names []string
+	//Valuation of the flag
+	Valuation Valuation
+	//Env is the name of the environment variable used to set the flag, empty
+	//if none
+	Env string
+	//Usage message of the flag
+	Usage string
+	//Separator used to split values of multivaluated flags, empty if none
+	Separator string
+	//Hidden flags are not part of the usage message
+	Hidden bool
+	//Deprecated holds the deprecation message, empty if the flag is not
+	//deprecated
+	Deprecated string
+	//IsSet reports whether the flag has been set by parsing
+	IsSet bool
+}
+
+//Lookup returns the description of the flag registered as name, or false if
+//name does not match any flag. name is resolved the same way it is on the
+//command line.
+func (fs *FlagSet) Lookup(name string) (Flag, bool) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	fitem, err := fs.lookup(name)
+	if err != nil {
+		return Flag{}, false
+	}
+	return fs.describe(fitem), true
+}
+
+//Names returns the first name of every flag, in the order the flags were
+//registered, the fields of the configuration structure first. Environment
+//only flags, which have no name, are left out.
+func (fs *FlagSet) Names() []string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	names := make([]string, 0, len(fs.flags))
+	for _, fitem := range fs.flags {
+		if len(fitem.names) != 0 {
+			names = append(names, fitem.names[0])
+		}
+	}
+	return names
+}
+
+//AllNames returns every name of every flag, aliases included, in the order of
+//Names
+func (fs *FlagSet) AllNames() []string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	names := make([]string, 0, len(fs.fmap))
+	for _, fitem := range fs.flags {
+		names = append(names, fitem.names...)
+	}
+	return names
+}
+
+//describe returns the exported description of fitem
+func (fs *FlagSet) describe(fitem *flag) Flag {
+	valuation := Boolean
+	switch fitem.valuation {
+	case mono:
+		valuation = Monovaluated
+	case multi:
+		valuation = Multivaluated
+	}
+	return Flag{
+		Names:      append([]string{}, fitem.names...),
+		Valuation:  valuation,
+		Env:        fs.envName(fitem),
+		Usage:      fitem.usage,
+		Separator:  fitem.separator,
+		Hidden:     fitem.hidden,
+		Deprecated: fitem.deprecated,
+		IsSet:      fitem.isSet,
+	}
+}
@@ -0,0 +1,57 @@
+package flag
+
+import "fmt"
+
+//Merge imports the flags of other into fs, with their tags, requirements,
+//OnSet functions and current values, so that they are parsed and described in
+//the usage message along with the flags of fs. Each flag keeps writing to the
+//configuration structure (or variable) it was declared with: parsing fs
+//populates both configuration structures. other is left untouched and is not
+//meant to be parsed once merged. Merge returns an error, and imports nothing,
+//if a name of other is already registered in fs.
+func (fs *FlagSet) Merge(other *FlagSet) error {
+	if other == nil || other == fs {
+		return fmt.Errorf("a FlagSet can only be merged with another one")
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+
+	for _, fitem := range other.flags {
+		for _, name := range fitem.names {
+			if _, ok := fs.fmap[name]; ok {
+				return fmt.Errorf("flag %s already registered", name)
+			}
+		}
+	}
+
+	merged := make(map[*flag]*flag, len(other.flags))
+	for _, fitem := range other.flags {
+		c := *fitem
+		c.names = append([]string{}, fitem.names...)
+		c.values = append([]string{}, fitem.values...)
+		c.defaults = append([]string{}, fitem.defaults...)
+		merged[fitem] = &c
+		for _, name := range c.names {
+			fs.fmap[name] = &c
+		}
+		fs.flags = append(fs.flags, &c)
+	}
+	for fitem, required := range other.requirements {
+		for _, ritem := range required {
+			fs.requirements[merged[fitem]] = append(fs.requirements[merged[fitem]], merged[ritem])
+		}
+	}
+	for fitem, fns := range other.onSet {
+		fs.onSet[merged[fitem]] = append(fs.onSet[merged[fitem]], fns...)
+	}
+	if fs.help == nil && other.help != nil {
+		fs.help = merged[other.help]
+	}
+	if fs.version == nil && other.version != nil {
+		fs.version = merged[other.version]
+		fs.versionString = other.versionString
+	}
+	return nil
+}
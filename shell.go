@@ -0,0 +1,96 @@
+package flag
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//ParseString parses cmdline like ParseArgs, once split into arguments the way
+//a POSIX shell does, without any expansion:
+// - arguments are separated by spaces, tabs and new lines;
+// - characters between single quotes are taken as is;
+// - characters between double quotes are taken as is, except \ which escapes
+//   ", \, $, ` and a new line (removed);
+// - out of quotes, \ escapes the following character, a new line being removed;
+// - quoted parts are joined with the characters around them: --name="a b" is
+//   one argument, and "" is an empty argument.
+//An unterminated quote or a trailing \ is an error. For example:
+// err := fs.ParseString(`-v --message "it's done" --path 'C:\tmp'`)
+//
+func (fs *FlagSet) ParseString(cmdline string) error {
+	args, err := splitCommandLine(cmdline)
+	if err != nil {
+		return fmt.Errorf("could not parse command line: %w", err)
+	}
+	return fs.ParseArgs(args)
+}
+
+//splitCommandLine returns the arguments of cmdline, split following the rules
+//described by ParseString
+func splitCommandLine(cmdline string) ([]string, error) {
+	args := make([]string, 0)
+	arg := &strings.Builder{}
+	inArg := false
+	runes := []rune(cmdline)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			if i == len(runes)-1 {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			if runes[i] != '\n' {
+				arg.WriteRune(runes[i])
+			}
+			inArg = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote at position %d", i)
+			}
+			arg.WriteString(string(runes[i+1 : end]))
+			i = end
+			inArg = true
+		case r == '"':
+			start := i
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				arg.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated double quote at position %d", start)
+			}
+			inArg = true
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+//indexRune returns the index of the first r in runes from index from, or -1
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
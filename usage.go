@@ -0,0 +1,130 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+//Usage writes to the output a usage message listing every flag
+func (fs *FlagSet) Usage() {
+	fmt.Fprintf(fs.output, "Usage of %s:\n", os.Args[0])
+	fs.PrintDefaults()
+}
+
+//HandleError returns the exit code hinted by err, the error returned by a
+//parsing: 0 if err is nil, ErrHelp, ErrVersion or ErrTerminal (the usage
+//message or version being written by the parsing already), 2 otherwise, err
+//and the usage message being written to the output. For example:
+// if err := fs.Parse(); err != nil {
+//	os.Exit(fs.HandleError(err))
+// }
+//
+func (fs *FlagSet) HandleError(err error) int {
+	if err == nil || errors.Is(err, ErrHelp) || errors.Is(err, ErrVersion) || errors.Is(err, ErrTerminal) {
+		return 0
+	}
+	fmt.Fprintf(fs.output, "%s\n", err)
+	fs.Usage()
+	return 2
+}
+
+//defaultGroup is the heading of the flags without a group tag, once flags are
+//grouped
+const defaultGroup = "Options"
+
+//PrintDefaults writes to the output the names, usage, environment variable
+//and default value of every flag, hidden flags excepted. The default value is
+//the one of the default tag, or the one held by the configuration structure
+//when the FlagSet was created, omitted if it is the zero value. If a flag has
+//a group tag, flags are listed under the heading of their group.
+func (fs *FlagSet) PrintDefaults() {
+	groups := make([]string, 0)
+	grouped := make(map[string][]*flag)
+	for _, fitem := range fs.flags {
+		if fitem.hidden {
+			continue
+		}
+		group := fitem.group
+		if len(group) == 0 {
+			group = defaultGroup
+		}
+		if _, ok := grouped[group]; !ok {
+			groups = append(groups, group)
+		}
+		grouped[group] = append(grouped[group], fitem)
+	}
+
+	for i, group := range groups {
+		if len(groups) > 1 || group != defaultGroup {
+			if i != 0 {
+				fmt.Fprintln(fs.output)
+			}
+			fmt.Fprintf(fs.output, "%s:\n", group)
+		}
+		for _, fitem := range grouped[group] {
+			fmt.Fprint(fs.output, fs.flagUsage(fitem))
+		}
+	}
+}
+
+//flagUsage returns the usage lines of fitem
+func (fs *FlagSet) flagUsage(fitem *flag) string {
+	b := &strings.Builder{}
+	env := fs.envName(fitem)
+	if len(fitem.names) == 0 && len(env) == 0 {
+		fmt.Fprintf(b, "  %s", fitem.fileKey)
+	} else if len(fitem.names) == 0 {
+		fmt.Fprintf(b, "  %s", env)
+	} else {
+		fmt.Fprintf(b, "  %s", strings.Join(fitem.names, ", "))
+	}
+	if fitem.valuation != none {
+		b.WriteString(" value")
+	}
+	details := make([]string, 0)
+	if len(fitem.usage) != 0 {
+		details = append(details, fitem.usage)
+	}
+	if len(fitem.names) == 0 && len(env) == 0 {
+		details = append(details, "(file only)")
+	} else if len(fitem.names) == 0 {
+		details = append(details, "(env only)")
+	} else if len(env) != 0 {
+		details = append(details, fmt.Sprintf("(env %s)", strings.Join(fs.envNames(fitem), ", ")))
+	}
+	if def := fitem.usageDefault(); len(def) != 0 {
+		details = append(details, fmt.Sprintf("(default: %s)", def))
+	}
+	if fitem.required {
+		details = append(details, "(required)")
+	}
+	if len(fitem.deprecated) != 0 {
+		details = append(details, fmt.Sprintf("(deprecated: %s)", fitem.deprecated))
+	}
+	if len(details) != 0 {
+		b.WriteString("\n    \t")
+		b.WriteString(strings.Join(details, " "))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+//usageDefault returns the default value of f shown in the usage message: the
+//value of its default tag, or the value held by its field when the flag was
+//created, so that parsing does not change it, the values of a multivaluated
+//flag being joined with its separator (or a comma). It is empty for a zero
+//value.
+func (f *flag) usageDefault() string {
+	values := f.defaults
+	if reflect.DeepEqual(values, f.fieldValues(reflect.New(f.field.Type()).Elem())) {
+		return ""
+	}
+	sep := f.separator
+	if len(sep) == 0 {
+		sep = ","
+	}
+	return strings.Join(values, sep)
+}
@@ -0,0 +1,44 @@
+package flag
+
+import "reflect"
+
+//Validate parses args like ParseArgs and returns the same errors, without
+//modifying the configuration structure nor the state of the FlagSet: values
+//are stored into a copy of the configuration, the functions registered with
+//OnSet are not called, the standard input is not read (see AllowStdin), the
+//usage message, version, deprecation and unset environment variable warnings
+//are not written, and a subcommand found is validated as well. It checks a
+//command line before using it.
+func (fs *FlagSet) Validate(args []string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	type state struct {
+		values []string
+		isSet  bool
+		source source
+		field  reflect.Value
+	}
+	states := make([]state, 0, len(fs.flags))
+	for _, fitem := range fs.flags {
+		states = append(states, state{values: fitem.values, isSet: fitem.isSet, source: fitem.source, field: fitem.field})
+		field := reflect.New(fitem.field.Type()).Elem()
+		field.Set(fitem.field)
+		fitem.values = append([]string{}, fitem.values...)
+		fitem.field = field
+	}
+	errs, positional, command, commandArgs, parsed, onSet := fs.errs, fs.args, fs.command, fs.commandArgs, fs.parsed, fs.onSet
+	stoppedBy, stdinFlag := fs.stoppedBy, fs.stdinFlag
+	fs.onSet = make(map[*flag][]func(values []string) error)
+	fs.dryRun = true
+
+	err := fs.run(args, "")
+
+	for i, fitem := range fs.flags {
+		fitem.values, fitem.isSet, fitem.source, fitem.field = states[i].values, states[i].isSet, states[i].source, states[i].field
+	}
+	fs.errs, fs.args, fs.command, fs.commandArgs, fs.parsed, fs.onSet = errs, positional, command, commandArgs, parsed, onSet
+	fs.stoppedBy, fs.stdinFlag = stoppedBy, stdinFlag
+	fs.dryRun = false
+	return err
+}
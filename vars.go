@@ -0,0 +1,92 @@
+package flag
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+//StringVar registers a monovaluated flag with names, storing its value in p,
+//set to def. It returns an error if a name is invalid or already registered.
+//Flags registered this way are parsed like the ones of the configuration
+//structure, which may be nil if every flag is registered with a Var method:
+// fs := flag.NewFlagSet(nil)
+// var host string
+// fs.StringVar(&host, []string{"-H", "--host"}, "localhost", "host to contact")
+//
+func (fs *FlagSet) StringVar(p *string, names []string, def string, usage string) error {
+	*p = def
+	return fs.addVar(p, names, usage, "")
+}
+
+//IntVar registers a monovaluated flag with names, storing its value in p, set
+//to def
+func (fs *FlagSet) IntVar(p *int, names []string, def int, usage string) error {
+	*p = def
+	return fs.addVar(p, names, usage, "")
+}
+
+//BoolVar registers a boolean flag with names, storing its value in p, set to
+//def. A flag defaulting to true is cleared with --name=false.
+func (fs *FlagSet) BoolVar(p *bool, names []string, def bool, usage string) error {
+	*p = def
+	return fs.addVar(p, names, usage, "")
+}
+
+//DurationVar registers a monovaluated flag with names, storing its value in p,
+//set to def. Values are parsed with time.ParseDuration.
+func (fs *FlagSet) DurationVar(p *time.Duration, names []string, def time.Duration, usage string) error {
+	*p = def
+	return fs.addVar(p, names, usage, "")
+}
+
+//StringSliceVar registers a multivaluated flag with names, storing its values
+//in p, set to def. Values are given by repeating the flag or separated with
+//commas.
+func (fs *FlagSet) StringSliceVar(p *[]string, names []string, def []string, usage string) error {
+	*p = append([]string{}, def...)
+	return fs.addVar(p, names, usage, ",")
+}
+
+//addVar registers a flag with names, bound to the variable p points to
+func (fs *FlagSet) addVar(p interface{}, names []string, usage string, separator string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	field := reflect.ValueOf(p).Elem()
+	kind := field.Kind()
+	valuation := mono
+	if kind == reflect.Slice {
+		valuation = multi
+	}
+	if kind == reflect.Bool {
+		valuation = none
+	}
+
+	fitem := newFlag(field, -1, valuation, kind)
+	for _, name := range names {
+		if err := checkName(name); err != nil {
+			return err
+		}
+		if _, ok := fs.fmap[name]; ok {
+			return fmt.Errorf("flag %s already registered", name)
+		}
+		fitem.names = append(fitem.names, name)
+	}
+	if len(fitem.names) == 0 {
+		return fmt.Errorf("a flag requires at least one name")
+	}
+	fitem.usage = usage
+	fitem.separator = separator
+	fitem.explicitSep = len(separator) != 0
+	if valuation == multi && !fitem.explicitSep {
+		fitem.separator = fs.defaultSep
+	}
+	fitem.defaults = fitem.fieldValues(field)
+
+	for _, name := range fitem.names {
+		fs.fmap[name] = fitem
+	}
+	fs.flags = append(fs.flags, fitem)
+	return nil
+}
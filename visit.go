@@ -0,0 +1,192 @@
+package flag
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+//Visit calls fn for each flag set on the command line, with environment
+//variables or from a file, in the order of the configuration structure. fn is
+//given the first name of the flag and the values as provided.
+func (fs *FlagSet) Visit(fn func(name string, values []string)) {
+	fs.mu.RLock()
+	flags := make([]visited, 0, len(fs.flags))
+	for _, fitem := range fs.flags {
+		if !fitem.isSet {
+			continue
+		}
+		flags = append(flags, visited{name: fitem.name(), values: fitem.given()})
+	}
+	fs.mu.RUnlock()
+	for _, v := range flags {
+		fn(v.name, v.values)
+	}
+}
+
+//VisitAll calls fn for each flag in the order of the configuration structure.
+//fn is given the first name of the flag and the values as provided for flags
+//which are set, or the values held by the configuration structure otherwise.
+func (fs *FlagSet) VisitAll(fn func(name string, values []string)) {
+	fs.mu.RLock()
+	flags := make([]visited, 0, len(fs.flags))
+	for _, fitem := range fs.flags {
+		flags = append(flags, visited{name: fitem.name(), values: fs.stringValues(fitem)})
+	}
+	fs.mu.RUnlock()
+	for _, v := range flags {
+		fn(v.name, v.values)
+	}
+}
+
+//visited holds the name and values of a flag collected under the read lock,
+//so that the function given to Visit and VisitAll is free to call fs
+type visited struct {
+	name   string
+	values []string
+}
+
+//stringValues returns the values provided for fitem if it is set, or the
+//values held by the configuration structure otherwise
+func (fs *FlagSet) stringValues(fitem *flag) []string {
+	if fitem.isSet {
+		return fitem.given()
+	}
+	return fitem.fieldValues(fitem.field)
+}
+
+//ArgsFromConfig returns the command line arguments which, parsed by a FlagSet
+//built on the same configuration structure type, reproduce the values held by
+//the configuration structure, environment only flags excepted. A boolean flag
+//is given by its name if true, a monovaluated flag as name=value, and a
+//multivaluated flag as name=value for each value, so that a value starting
+//with a dash is not taken for a flag. Unset pointers, false booleans (unless
+//true when the FlagSet was created) and empty slices are omitted. A greedy
+//flag, which takes every following argument, is given last, once, followed by
+//its values: a single greedy flag holding values can be reproduced. Values equal to the ones held when the FlagSet was created are
+//included unless OmitDefaults is enabled.
+func (fs *FlagSet) ArgsFromConfig() []string {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	args := make([]string, 0)
+	greedy := make([]string, 0)
+	for _, fitem := range fs.flags {
+		if len(fitem.names) == 0 {
+			continue
+		}
+		fname := fitem.names[0]
+		values := fitem.fieldValues(fitem.field)
+		if fs.omitDefaults && reflect.DeepEqual(values, fitem.defaults) {
+			continue
+		}
+		if fitem.valuation == none {
+			if len(values) == 1 && values[0] == "true" {
+				args = append(args, fname)
+			} else if len(values) == 1 && reflect.DeepEqual(fitem.defaults, []string{"true"}) {
+				args = append(args, fname+"="+values[0])
+			}
+			continue
+		}
+		if fitem.greedy && len(values) != 0 {
+			greedy = append(greedy, fname)
+			greedy = append(greedy, values...)
+			continue
+		}
+		for _, v := range values {
+			args = append(args, fname+"="+v)
+		}
+	}
+	return append(args, greedy...)
+}
+
+//OmitDefaults sets whether ArgsFromConfig omits the flags whose values are the
+//ones held by the configuration structure when the FlagSet was created
+func (fs *FlagSet) OmitDefaults(omit bool) {
+	fs.omitDefaults = omit
+}
+
+//given returns a copy of the values provided for the flag
+func (f *flag) given() []string {
+	return append([]string{}, f.values...)
+}
+
+//fieldValues returns the string representation of the values held by v, the
+//field associated with the flag
+func (f *flag) fieldValues(v reflect.Value) []string {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return []string{}
+		}
+		v = v.Elem()
+	}
+	if f.isJSON {
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return []string{}
+		}
+		return []string{string(b)}
+	}
+	if v.Kind() == reflect.Map {
+		values := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			values = append(values, fmt.Sprintf("%v=%s", k, f.format(v.MapIndex(k))))
+		}
+		sort.Strings(values)
+		return values
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []string{f.format(v)}
+	}
+	if f.valuation == mono {
+		switch f.encoding {
+		case "base64":
+			return []string{base64.StdEncoding.EncodeToString(v.Bytes())}
+		case "hex":
+			return []string{hex.EncodeToString(v.Bytes())}
+		}
+		return []string{string(v.Bytes())}
+	}
+	values := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		values = append(values, f.format(v.Index(i)))
+	}
+	return values
+}
+
+//format returns the string representation of v, a value of the flag
+func (f *flag) format(v reflect.Value) string {
+	if f.enum != nil {
+		value := formatInteger(v)
+		for _, e := range f.enum {
+			if e.value == value {
+				return e.name
+			}
+		}
+		return value
+	}
+	if f.isRune && v.Kind() == reflect.Int32 {
+		return string(rune(v.Int()))
+	}
+	if v.Type() != durationType {
+		if s := formatInteger(v); len(s) != 0 {
+			return s
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+//formatInteger returns the decimal representation of v, an integer, ignoring
+//any String method of its type, or an empty string if v is not an integer
+func formatInteger(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return ""
+}